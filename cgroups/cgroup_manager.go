@@ -41,6 +41,32 @@ func (c *CgroupManager) Set(res *subsystems.ResourceConfig) error {
 	return nil
 }
 
+// 获取 cgroup 中所有进程的 PID，合并各个 subsystem 中的进程，所有 subsystem 都读取失败时返回错误
+func (c *CgroupManager) GetPids() ([]int, error) {
+	var pids []int
+	var lastErr error
+	found := map[int]bool{}
+	read := false
+	for _, subSysIns := range subsystems.Instance {
+		subsystemPids, err := subsystems.GetCgroupPids(subSysIns.Name(), c.Path)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		read = true
+		for _, pid := range subsystemPids {
+			if !found[pid] {
+				found[pid] = true
+				pids = append(pids, pid)
+			}
+		}
+	}
+	if !read {
+		return nil, lastErr
+	}
+	return pids, nil
+}
+
 // 释放 cgroup
 func (c *CgroupManager) Destroy() error {
 	for _, subSysIns := range subsystems.Instance {
@@ -28,4 +28,15 @@ func TestNewCgroupManager(t *testing.T) {
 	if err := manager.Apply(os.Getpid()); err != nil {
 		t.Fatalf("Apply fail :%v\n", err)
 	}
+	pids, err := manager.GetPids()
+	if err != nil {
+		t.Fatalf("GetPids fail :%v\n", err)
+	}
+	found := false
+	for _, pid := range pids {
+		found = found || pid == os.Getpid()
+	}
+	if !found {
+		t.Fatalf("GetPids %v should contain %d", pids, os.Getpid())
+	}
 }
@@ -0,0 +1,181 @@
+package subsystems
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// 等待 oom_kill 计数增加时的检查间隔
+const oomCheckInterval = 10 * time.Millisecond
+
+// 监听 cgroup 中发生的 OOM，每次 OOM killer 杀死进程时向 Events 发送事件，Close 之后 Events 被关闭
+type OOMWatcher struct {
+	Events <-chan struct{}
+	events chan struct{}
+	file   *os.File
+	// 被唤醒时检查是否真的发生了 OOM
+	check  func() bool
+	closed chan struct{}
+}
+
+/*
+监听 cgroupPath 对应 cgroup 的 OOM 事件：
+	1. cgroup v1 通过 cgroup.event_control 为 memory.oom_control 注册 eventfd，发生 OOM 时 eventfd 可读
+	2. cgroup v2 通过 inotify 监听 memory.events 的修改，其中的 oom_kill 计数增加时表示发生了 OOM
+*/
+func WatchOOM(cgroupPath string) (*OOMWatcher, error) {
+	if FindCgroupMountPoint("memory") != "" {
+		return watchOOMv1(cgroupPath)
+	}
+	if root := FindCgroup2MountPoint(); root != "" {
+		return watchOOMv2(path.Join(root, cgroupPath))
+	}
+	return nil, fmt.Errorf("memory cgroup is not mounted")
+}
+
+func watchOOMv1(cgroupPath string) (*OOMWatcher, error) {
+	subsystemCgroupPath, err := GetCgroupPath("memory", cgroupPath, false)
+	if err != nil {
+		return nil, err
+	}
+	oomControl, err := os.Open(path.Join(subsystemCgroupPath, "memory.oom_control"))
+	if err != nil {
+		return nil, err
+	}
+	defer oomControl.Close()
+	efd, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("create eventfd error: %v", err)
+	}
+	file := os.NewFile(uintptr(efd), "oom-eventfd")
+	control := fmt.Sprintf("%d %d", efd, oomControl.Fd())
+	if err := ioutil.WriteFile(path.Join(subsystemCgroupPath, "cgroup.event_control"), []byte(control), 0644); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("register oom event error: %v", err)
+	}
+	// 一次 OOM 可能多次唤醒 eventfd，删除 cgroup 时 eventfd 同样会被唤醒，通过 oom_kill 计数判断是否有新的 OOM
+	return newOOMWatcher(file, oomKillIncreased(path.Join(subsystemCgroupPath, "memory.oom_control"), time.Second)), nil
+}
+
+func watchOOMv2(cgroupPath string) (*OOMWatcher, error) {
+	eventsPath := path.Join(cgroupPath, "memory.events")
+	if _, err := os.Stat(eventsPath); err != nil {
+		return nil, err
+	}
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("create inotify error: %v", err)
+	}
+	file := os.NewFile(uintptr(fd), "oom-inotify")
+	if _, err := unix.InotifyAddWatch(fd, eventsPath, unix.IN_MODIFY); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("watch %s error: %v", eventsPath, err)
+	}
+	return newOOMWatcher(file, oomKillIncreased(eventsPath, 0)), nil
+}
+
+/*
+返回检查 oom_kill 计数是否增加的函数：
+	1. cgroup 被删除时读取失败，返回 false
+	2. 旧内核没有 oom_kill 计数，每次唤醒都视为 OOM
+	3. v1 的 eventfd 在 OOM killer 杀死进程之前就被唤醒，在 wait 时间内等待计数增加
+*/
+func oomKillIncreased(file string, wait time.Duration) func() bool {
+	last, _ := readOOMKillCount(file)
+	return func() bool {
+		deadline := time.Now().Add(wait)
+		for {
+			count, err := readOOMKillCount(file)
+			if err != nil {
+				return false
+			}
+			if count < 0 {
+				return true
+			}
+			if count > last {
+				last = count
+				return true
+			}
+			if time.Now().After(deadline) {
+				return false
+			}
+			time.Sleep(oomCheckInterval)
+		}
+	}
+}
+
+func newOOMWatcher(file *os.File, check func() bool) *OOMWatcher {
+	events := make(chan struct{}, 1)
+	w := &OOMWatcher{Events: events, events: events, file: file, check: check, closed: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+func (w *OOMWatcher) run() {
+	defer close(w.closed)
+	defer close(w.events)
+	defer w.file.Close()
+	buf := make([]byte, 4096)
+	for {
+		// Close 设置读取超时后 Read 返回错误
+		if _, err := w.file.Read(buf); err != nil {
+			return
+		}
+		if w.check() {
+			w.events <- struct{}{}
+		}
+	}
+}
+
+// 停止监听，等待已经读取到的事件被发送
+func (w *OOMWatcher) Close() {
+	_ = w.file.SetReadDeadline(time.Unix(1, 0))
+	<-w.closed
+}
+
+// 读取 cgroup 中 OOM killer 杀死进程的次数，cgroup v1 读取 memory.oom_control，cgroup v2 读取 memory.events
+func OOMKillCount(cgroupPath string) (int, error) {
+	if FindCgroupMountPoint("memory") != "" {
+		subsystemCgroupPath, err := GetCgroupPath("memory", cgroupPath, false)
+		if err != nil {
+			return 0, err
+		}
+		return readOOMKillCount(path.Join(subsystemCgroupPath, "memory.oom_control"))
+	}
+	if root := FindCgroup2MountPoint(); root != "" {
+		return readOOMKillCount(path.Join(root, cgroupPath, "memory.events"))
+	}
+	return 0, fmt.Errorf("memory cgroup is not mounted")
+}
+
+func readOOMKillCount(file string) (int, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return parseOOMKillCount(string(content))
+}
+
+// 解析 "oom_kill N" 形式的计数，旧内核的 memory.oom_control 中没有该项时返回 -1
+func parseOOMKillCount(content string) (int, error) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			count, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return 0, fmt.Errorf("invalid oom_kill count %s", fields[1])
+			}
+			return count, nil
+		}
+	}
+	return -1, nil
+}
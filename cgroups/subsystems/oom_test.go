@@ -0,0 +1,52 @@
+package subsystems
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseOOMKillCount(t *testing.T) {
+	cases := map[string]int{
+		"oom_kill_disable 0\nunder_oom 0\noom_kill 3\n":                3,
+		"low 0\nhigh 0\nmax 12\noom 2\noom_kill 1\noom_group_kill 0\n": 1,
+		"oom_kill_disable 0\nunder_oom 0\n":                            -1,
+	}
+	for content, expect := range cases {
+		if count, err := parseOOMKillCount(content); err != nil || count != expect {
+			t.Fatalf("parseOOMKillCount %q got %d error %v, expect %d", content, count, err, expect)
+		}
+	}
+	if _, err := parseOOMKillCount("oom_kill x\n"); err == nil {
+		t.Fatal("invalid count should fail")
+	}
+}
+
+func TestWatchOOM(t *testing.T) {
+	testCgroup := "test_oom_watch"
+	memSubSys := MemorySubSystem{}
+	if err := memSubSys.Set(testCgroup, &ResourceConfig{}); err != nil {
+		t.Fatalf("cgroup fail %v\n", err)
+	}
+	defer memSubSys.Remove(testCgroup)
+
+	watcher, err := WatchOOM(testCgroup)
+	if err != nil {
+		t.Fatalf("WatchOOM error %v\n", err)
+	}
+	if count, err := OOMKillCount(testCgroup); err != nil || count != 0 {
+		t.Fatalf("OOMKillCount got %d error %v\n", count, err)
+	}
+	// 删除 cgroup 唤醒 eventfd 时不应当产生 OOM 事件
+	if err := memSubSys.Remove(testCgroup); err != nil {
+		t.Fatalf("cgroup remove %v\n", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	watcher.Close()
+	for range watcher.Events {
+		t.Fatal("unexpected oom event")
+	}
+	if _, err := os.Stat(FindCgroupMountPoint("memory") + "/" + testCgroup); !os.IsNotExist(err) {
+		t.Fatalf("cgroup should be removed, stat error %v", err)
+	}
+}
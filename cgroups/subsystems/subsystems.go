@@ -3,11 +3,11 @@ package subsystems
 // ResourceConfig 传递资源限制配置
 type ResourceConfig struct {
 	// 内存限制
-	MemoryLimit string
+	MemoryLimit string `json:"memoryLimit"`
 	// CPU 时间片权重
-	CpuShare string
+	CpuShare string `json:"cpuShare"`
 	// CPU 核心数
-	CpuSet string
+	CpuSet string `json:"cpuSet"`
 }
 
 // Subsystem 接口，每个 Subsystem 可以实现下面的 4 个接口
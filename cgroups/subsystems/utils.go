@@ -3,8 +3,10 @@ package subsystems
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 )
 
@@ -36,6 +38,28 @@ func FindCgroupMountPoint(subsystem string) string {
 	return ""
 }
 
+// 通过 /proc/self/mountinfo 找出 cgroup v2 的挂载目录
+func FindCgroup2MountPoint() string {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 42 32 0:38 / /sys/fs/cgroup/unified rw,relatime - cgroup2 cgroup2 rw
+		fields := strings.Split(scanner.Text(), " ")
+		// 可选字段的数量不固定，文件系统类型在 "-" 之后
+		for i, field := range fields {
+			if field == "-" && i+1 < len(fields) && fields[i+1] == "cgroup2" {
+				return fields[4]
+			}
+		}
+	}
+	return ""
+}
+
 // 得到 cgroup 在文件系统中的绝对路径
 func GetCgroupPath(subsystem string, cgroupPath string, autoCreate bool) (string, error) {
 	cgroupRoot := FindCgroupMountPoint(subsystem)
@@ -50,3 +74,24 @@ func GetCgroupPath(subsystem string, cgroupPath string, autoCreate bool) (string
 		return "", fmt.Errorf("cgroup path error %v", err)
 	}
 }
+
+// 读取 cgroup 中所有进程的 PID
+func GetCgroupPids(subsystem string, cgroupPath string) ([]int, error) {
+	subsystemCgroupPath, err := GetCgroupPath(subsystem, cgroupPath, false)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(path.Join(subsystemCgroupPath, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, line := range strings.Fields(string(content)) {
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pid %s in cgroup %s", line, cgroupPath)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
@@ -11,11 +11,26 @@ import (
 
 // 用子目录集合制作 ${imageName}.tar 的镜像
 func commitContainer(containerName, imageName string) {
-	mntURL := fmt.Sprintf(container.MntUrl, containerName)
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		log.Errorf("Get container %s info error %v", containerName, err)
+		return
+	}
+	mntURL := fmt.Sprintf(container.MntUrl, containerInfo.Id)
 	mntURL += "/"
-	imageTar := container.RootUrl + "/" + imageName + ".tar"
+	imageTar := container.ImageTarUrl(imageName)
 	fmt.Printf("save to image: %s\n", imageTar)
 	if _, err := exec.Command("tar", "-czf", imageTar, "-C", mntURL, ".").CombinedOutput(); err != nil {
 		log.Errorf("Tar folder %s error %v", mntURL, err)
+		return
+	}
+	// 新镜像沿用容器所用镜像的配置，同时标记为 ydocker 管理的镜像
+	imageConfig, err := container.LoadImageConfig(containerInfo.Image)
+	if err != nil {
+		log.Errorf("Load image %s config error %v", containerInfo.Image, err)
+		imageConfig = &container.ImageConfig{}
+	}
+	if err := imageConfig.Save(imageName); err != nil {
+		log.Errorf("Save image %s config error %v", imageName, err)
 	}
 }
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourtion/ydocker/container"
+)
+
+// 拆分 cp 的参数，"容器名:路径" 的形式返回容器名和路径，宿主机路径返回空的容器名
+func splitCpArg(arg string) (string, string) {
+	if strings.HasPrefix(arg, "/") || strings.HasPrefix(arg, ".") {
+		return "", arg
+	}
+	parts := strings.SplitN(arg, ":", 2)
+	if len(parts) != 2 || strings.Contains(parts[0], "/") {
+		return "", arg
+	}
+	return parts[0], parts[1]
+}
+
+// 获取复制时使用的根目录，宿主机为 "/"，容器为其挂载后的文件系统
+func copyRoot(containerName string) (string, func(), error) {
+	if containerName == "" {
+		return "/", func() {}, nil
+	}
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return "", nil, fmt.Errorf("get container %s info error: %v", containerName, err)
+	}
+	return container.MountContainerRoot(containerInfo.Id, containerInfo.Image)
+}
+
+// 在宿主机与容器之间复制文件，路径为 "-" 时从标准输入读取或向标准输出写入 tar 流
+func copyContainer(src, dst string, followLink bool) error {
+	srcContainer, srcPath := splitCpArg(src)
+	dstContainer, dstPath := splitCpArg(dst)
+	if srcContainer != "" && dstContainer != "" {
+		return fmt.Errorf("copying between containers is not supported")
+	}
+	if srcContainer == "" && dstContainer == "" {
+		return fmt.Errorf("must specify at least one container source")
+	}
+	// 宿主机的根目录为 "/"，相对路径需要先基于当前目录转换为绝对路径
+	var err error
+	if srcContainer == "" {
+		if srcPath, err = container.HostPath(srcPath); err != nil {
+			return err
+		}
+	}
+	if dstContainer == "" {
+		if dstPath, err = container.HostPath(dstPath); err != nil {
+			return err
+		}
+	}
+
+	srcRoot, srcRelease, err := copyRoot(srcContainer)
+	if err != nil {
+		return err
+	}
+	defer srcRelease()
+	dstRoot, dstRelease, err := copyRoot(dstContainer)
+	if err != nil {
+		return err
+	}
+	defer dstRelease()
+
+	switch {
+	case srcPath == "-":
+		return container.ExtractArchive(os.Stdin, dstRoot, dstPath)
+	case dstPath == "-":
+		name := filepath.Base(filepath.Clean("/" + srcPath))
+		if strings.HasSuffix(srcPath, "/.") {
+			name = ""
+		}
+		return container.ArchivePath(os.Stdout, srcRoot, srcPath, container.ArchiveOptions{Name: name, FollowLink: followLink})
+	}
+	return container.CopyPath(srcRoot, srcPath, dstRoot, dstPath, followLink)
+}
@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/yourtion/ydocker/container"
+)
+
+// 输出容器可写层相对于镜像的文件变更
+func diffContainer(containerName string, jsonOutput bool) error {
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return fmt.Errorf("get container %s info error: %v", containerName, err)
+	}
+	if containerInfo.Image == "" {
+		return fmt.Errorf("container %s has no image recorded", containerName)
+	}
+	changes, err := container.ContainerChanges(containerInfo.Id, containerInfo.Image)
+	if err != nil {
+		return fmt.Errorf("diff container %s error: %v", containerName, err)
+	}
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(changes)
+	}
+	for _, change := range changes {
+		fmt.Printf("%s %s\n", change.Kind, change.Path)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yourtion/ydocker/container"
+)
+
+/*
+按照 JSON Lines 输出事件文件中记录的事件：
+	1. 只输出 since 之后的事件，没有指定 since 时只输出之后产生的新事件
+	2. 指定 until 时输出到 until 为止，否则持续跟踪事件文件输出新的事件
+*/
+func streamEvents(since, until string, filters []string) error {
+	filter, err := container.ParseEventFilters(filters)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	sinceTime, untilTime := now, time.Time{}
+	if since != "" {
+		if sinceTime, err = container.ParseEventTime(since, now); err != nil {
+			return err
+		}
+	}
+	if until != "" {
+		if untilTime, err = container.ParseEventTime(until, now); err != nil {
+			return err
+		}
+	}
+	finished := func() bool {
+		return !untilTime.IsZero() && time.Now().After(untilTime)
+	}
+
+	file, err := openEventsFile(finished)
+	if err != nil || file == nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var pending string
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			// 还没有写完的行留到下一次读取
+			pending += line
+			if finished() {
+				return nil
+			}
+			time.Sleep(stopPollInterval)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read events error: %v", err)
+		}
+		line = strings.TrimSpace(pending + line)
+		pending = ""
+		var event container.Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			continue
+		}
+		eventTime := time.Unix(0, event.TimeNano)
+		if eventTime.Before(sinceTime) {
+			continue
+		}
+		if !untilTime.IsZero() && eventTime.After(untilTime) {
+			return nil
+		}
+		if filter.MatchEvent(&event) {
+			fmt.Println(line)
+		}
+	}
+}
+
+// 打开事件文件，还没有产生过事件时等待文件被创建，finished 返回 true 时不再等待
+func openEventsFile(finished func() bool) (*os.File, error) {
+	for {
+		file, err := os.Open(container.EventsPath())
+		if err == nil {
+			return file, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("open events file error: %v", err)
+		}
+		if finished() {
+			return nil, nil
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
@@ -28,27 +28,33 @@ func execContainer(containerName string, comArray []string) {
 	log.Infof("container pid %s", pid)
 	log.Infof("command %s", cmdStr)
 
-	// 简单地 fork 出来一个进程，不 需要这个进程拥有什么命名空间的隔离，然后把这个进程的标准输入输出都绑定到宿主机上
-	cmd := exec.Command("/proc/self/exe", "exec")
+	cmd := newExecCommand(pid, cmdStr)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-
-	if err := os.Setenv(EnvExecPid, pid); err != nil {
-		log.Errorf("Setenv EnvExecPid error %v", err)
-	}
-	if err := os.Setenv(EnvExecCmd, cmdStr); err != nil {
-		log.Errorf("Setenv EnvExecPid error %v", err)
+	if err := cmd.Run(); err != nil {
+		// 命令的退出码作为 exec 的退出码
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitCodeOf(exitErr.ProcessState))
+		}
+		log.Errorf("Exec container %s error %v", containerName, err)
 	}
+}
 
+/*
+创建在容器 Namespace 中执行命令的进程，exec 与健康检查共用
+	1. 简单地 fork 出来一个进程，不需要这个进程拥有什么命名空间的隔离
+	2. 通过环境变量传递容器的 PID 与命令，由 nsenter 包中的 C 代码进入容器的 Namespace 后执行命令
+	3. 进程的退出码为命令的退出码
+*/
+func newExecCommand(pid, cmdStr string) *exec.Cmd {
+	cmd := exec.Command("/proc/self/exe", "exec")
 	// 获取对应的 PID 环境变量，其实也就是容器的环境变量
 	containerEnvs := getEnvsByPid(pid)
 	// 将宿主机的环境变量和容器的环境变量都放置到 exec 进程内
-	cmd.Env = append(os.Environ(), containerEnvs...)
-
-	if err := cmd.Run(); err != nil {
-		log.Errorf("Exec container %s error %v", containerName, err)
-	}
+	cmd.Env = append(os.Environ(), EnvExecPid+"="+pid, EnvExecCmd+"="+cmdStr)
+	cmd.Env = append(cmd.Env, containerEnvs...)
+	return cmd
 }
 
 // 根据指定的 PID 来获取对应进程的环境变量
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yourtion/ydocker/container"
+)
+
+// 将容器的文件系统导出为 tar，output 为空时输出到标准输出
+func exportContainer(containerName, output string) error {
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return fmt.Errorf("get container %s info error: %v", containerName, err)
+	}
+	root, release, err := container.MountContainerRoot(containerInfo.Id, containerInfo.Image)
+	if err != nil {
+		return fmt.Errorf("mount container %s error: %v", containerName, err)
+	}
+	defer release()
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("create file %s error: %v", output, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	// 数据卷不属于容器的文件系统，不进行导出
+	return container.ArchivePath(w, root, "/", container.ArchiveOptions{OneFileSystem: true})
+}
+
+// 从 rootfs 的 tar 文件导入镜像，source 为 "-" 时从标准输入读取
+func importImage(source, imageName string, changes []string) error {
+	containers, err := getAllContainerInfos()
+	if err != nil {
+		return err
+	}
+	for _, item := range containers {
+		if container.ImageKey(item.Image) == container.ImageKey(imageName) {
+			return fmt.Errorf("image %s is used by container %s", imageName, item.Name)
+		}
+	}
+
+	var r io.Reader = os.Stdin
+	if source != "-" {
+		file, err := os.Open(source)
+		if err != nil {
+			return fmt.Errorf("open file %s error: %v", source, err)
+		}
+		defer file.Close()
+		r = file
+	}
+	if err := container.ImportImage(r, imageName, changes); err != nil {
+		return fmt.Errorf("import image %s error: %v", imageName, err)
+	}
+	fmt.Printf("import image: %s\n", container.ImageTarUrl(imageName))
+	return nil
+}
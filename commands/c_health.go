@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/yourtion/ydocker/container"
+)
+
+/*
+按照健康检查配置周期性地在容器中执行检查命令，返回停止检查的函数
+	1. 容器每次启动后健康状态重置为 starting
+	2. 检查结果记录在容器信息中，健康状态变化时记录 health_status 事件
+	3. 容器变为 unhealthy 且设置了重启策略时杀死容器，由父进程按照重启策略重启
+*/
+func startHealthMonitor(containerInfo *container.Info) func() {
+	config := containerInfo.Healthcheck
+	if !config.Enabled() {
+		return func() {}
+	}
+	containerId, pid := containerInfo.Id, containerInfo.Pid
+	if _, err := updateContainerInfo(containerId, func(info *container.Info) error {
+		info.Health = &container.Health{Status: container.HealthStarting}
+		return nil
+	}); err != nil {
+		log.Errorf("Reset container %s health error %v", containerInfo.Name, err)
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		startedAt := time.Now()
+		ticker := time.NewTicker(config.IntervalOrDefault())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			result := runHealthProbe(pid, config)
+			starting := time.Since(startedAt) < config.StartPeriod
+			changed := false
+			latest, err := updateContainerInfo(containerId, func(info *container.Info) error {
+				if info.Health == nil {
+					info.Health = &container.Health{Status: container.HealthStarting}
+				}
+				changed = info.Health.Record(result, config.RetriesOrDefault(), starting)
+				return nil
+			})
+			if err != nil {
+				log.Errorf("Record container %s health error %v", containerId, err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			log.Infof("container %s is %s", latest.Name, latest.Health.Status)
+			container.LogContainerEvent(latest, "health_status: "+latest.Health.Status, nil)
+			if latest.Health.Status == container.Unhealthy && latest.RestartPolicy.Name != container.RestartNo {
+				log.Infof("kill unhealthy container %s to restart it", latest.Name)
+				if p, err := strconv.Atoi(pid); err == nil {
+					_ = syscall.Kill(p, syscall.SIGKILL)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// 通过 exec 的方式在容器中执行一次检查命令，超时时杀死检查命令
+func runHealthProbe(pid string, config *container.HealthConfig) container.HealthResult {
+	result := container.HealthResult{Start: time.Now().Format(container.TimeFormat)}
+	var output bytes.Buffer
+	cmd := newExecCommand(pid, config.ShellCommand())
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// 检查命令在独立的进程组中运行，超时时杀死整个进程组
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		result.End = time.Now().Format(container.TimeFormat)
+		result.ExitCode = -1
+		result.Output = err.Error()
+		return result
+	}
+	timeout := config.TimeoutOrDefault()
+	timer := time.AfterFunc(timeout, func() {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	})
+	err := cmd.Wait()
+	timedOut := !timer.Stop()
+	result.End = time.Now().Format(container.TimeFormat)
+	result.Output = output.String()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		result.ExitCode = -1
+		result.Output = err.Error()
+		return result
+	}
+	if timedOut {
+		result.ExitCode = -1
+		result.Output = fmt.Sprintf("health check exceeded timeout (%s)", timeout)
+		return result
+	}
+	result.ExitCode = exitCodeOf(cmd.ProcessState)
+	return result
+}
@@ -0,0 +1,341 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/yourtion/ydocker/container"
+	"github.com/yourtion/ydocker/network"
+)
+
+// inspect 支持的对象类型
+const (
+	inspectContainer = "container"
+	inspectImage     = "image"
+	inspectNetwork   = "network"
+	inspectVolume    = "volume"
+)
+
+// 容器的详细信息，字段与 docker inspect 的输出保持一致，便于使用相同的 --format 模板
+type containerView struct {
+	Id              string
+	Name            string
+	Created         string
+	Path            string
+	Args            []string
+	Image           string
+	State           containerStateView
+	RestartCount    int
+	LogPath         string
+	HostnamePath    string
+	HostsPath       string
+	Config          containerConfigView
+	HostConfig      hostConfigView
+	NetworkSettings networkSettingsView
+	Mounts          []mountView
+}
+
+type containerStateView struct {
+	Status     string
+	Running    bool
+	Paused     bool
+	Restarting bool
+	OOMKilled  bool
+	Dead       bool
+	Pid        int
+	ExitCode   int
+	StartedAt  string
+	FinishedAt string
+	Health     *container.Health `json:",omitempty"`
+}
+
+type containerConfigView struct {
+	Hostname    string
+	Domainname  string
+	Image       string
+	Cmd         []string
+	Env         []string
+	StopSignal  string
+	Labels      map[string]string
+	Healthcheck *container.HealthConfig `json:",omitempty"`
+}
+
+type hostConfigView struct {
+	Memory        string
+	CpuShares     string
+	CpusetCpus    string
+	CgroupPath    string
+	NetworkMode   string
+	PortBindings  []string
+	RestartPolicy container.RestartPolicy
+	AutoRemove    bool
+	Ulimits       []*container.Ulimit
+	Sysctls       map[string]string
+}
+
+type networkSettingsView struct {
+	IPAddress string
+	Ports     []string
+	Networks  map[string]endpointView
+}
+
+type endpointView struct {
+	IPAddress string
+	Gateway   string
+}
+
+type mountView struct {
+	Type        string
+	Source      string
+	Destination string
+}
+
+// 镜像的详细信息
+type imageView struct {
+	Name      string
+	Tar       string
+	Size      int64
+	LayerSize int64
+	Config    *container.ImageConfig
+}
+
+// 网络的详细信息
+type networkView struct {
+	Name       string
+	Driver     string
+	Subnet     string
+	Gateway    string
+	Containers map[string]endpointView
+}
+
+// 数据卷的详细信息，数据卷是通过 -v 挂载到容器内的宿主机目录
+type volumeView struct {
+	Name       string
+	Mountpoint string
+	Containers []string
+}
+
+/*
+查看容器、镜像、网络或数据卷的详细信息：
+	1. 未指定类型时按照容器、镜像、网络、数据卷的顺序查找
+	2. 默认输出 JSON 数组，指定 format 时对每个对象执行 Go 模板
+*/
+func inspectObjects(names []string, objectType, format string) error {
+	switch objectType {
+	case "", inspectContainer, inspectImage, inspectNetwork, inspectVolume:
+	default:
+		return fmt.Errorf("unknown type %s", objectType)
+	}
+	if err := network.Init(); err != nil {
+		return fmt.Errorf("init network error: %v", err)
+	}
+	containers, err := getAllContainerInfos()
+	if err != nil {
+		return err
+	}
+	var tmpl *template.Template
+	if format != "" {
+		if tmpl, err = parseFormat(format); err != nil {
+			return err
+		}
+	}
+	views := make([]interface{}, 0, len(names))
+	var failed []string
+	for _, name := range names {
+		view, err := inspectObject(containers, name, objectType)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "Error:", err)
+			failed = append(failed, name)
+			continue
+		}
+		views = append(views, view)
+	}
+	if tmpl != nil {
+		for _, view := range views {
+			if err := tmpl.Execute(os.Stdout, view); err != nil {
+				return fmt.Errorf("execute template error: %v", err)
+			}
+			fmt.Println()
+		}
+	} else {
+		content, err := json.MarshalIndent(views, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(content))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("no such object: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// 解析 --format 模板，支持 {{json .}} 输出 JSON
+func parseFormat(format string) (*template.Template, error) {
+	return template.New("format").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			content, err := json.Marshal(v)
+			return string(content), err
+		},
+		"join":  strings.Join,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}).Parse(format)
+}
+
+func inspectObject(containers []*container.Info, name, objectType string) (interface{}, error) {
+	all := objectType == ""
+	if all || objectType == inspectContainer {
+		if info, err := container.FindContainer(containers, name); err == nil {
+			return newContainerView(reconcileContainerInfo(info)), nil
+		} else if !all {
+			return nil, err
+		}
+	}
+	if all || objectType == inspectImage {
+		if _, err := os.Stat(container.ImageTarUrl(name)); err == nil {
+			return newImageView(name)
+		} else if !all {
+			return nil, fmt.Errorf("no such image: %s", name)
+		}
+	}
+	if all || objectType == inspectNetwork {
+		if nw, ok := network.GetNetwork(name); ok {
+			return newNetworkView(nw, containers), nil
+		} else if !all {
+			return nil, fmt.Errorf("no such network: %s", name)
+		}
+	}
+	if all || objectType == inspectVolume {
+		if view := newVolumeView(name, containers); view != nil {
+			return view, nil
+		} else if !all {
+			return nil, fmt.Errorf("no such volume: %s", name)
+		}
+	}
+	return nil, fmt.Errorf("no such object: %s", name)
+}
+
+func newContainerView(info *container.Info) *containerView {
+	pid, _ := strconv.Atoi(info.Pid)
+	view := &containerView{
+		Id:           info.Id,
+		Name:         info.Name,
+		Created:      info.CreatedTime,
+		Image:        info.Image,
+		RestartCount: info.RestartCount,
+		LogPath:      fmt.Sprintf(container.DefaultInfoLocation, info.Id) + container.LogFile,
+		HostnamePath: fmt.Sprintf(container.DefaultInfoLocation, info.Id) + container.HostnameFile,
+		HostsPath:    fmt.Sprintf(container.DefaultInfoLocation, info.Id) + container.HostsFile,
+		State: containerStateView{
+			Status:     info.Status,
+			Running:    info.Status == container.RUNNING,
+			Paused:     info.Status == container.PAUSED,
+			Restarting: info.Status == container.RESTARTING,
+			OOMKilled:  info.OOMKilled,
+			Dead:       info.Status == container.DEAD,
+			Pid:        pid,
+			ExitCode:   info.ExitCode,
+			StartedAt:  info.StartedTime,
+			FinishedAt: info.FinishedTime,
+			Health:     info.Health,
+		},
+		Config: containerConfigView{
+			Hostname:    info.Hostname,
+			Domainname:  info.Domainname,
+			Image:       info.Image,
+			Cmd:         info.Args,
+			Env:         info.Env,
+			StopSignal:  info.StopSignal,
+			Labels:      info.Labels,
+			Healthcheck: info.Healthcheck,
+		},
+		HostConfig: hostConfigView{
+			CgroupPath:    info.CgroupPath,
+			NetworkMode:   info.Network,
+			PortBindings:  info.PortMapping,
+			RestartPolicy: info.RestartPolicy,
+			AutoRemove:    info.AutoRemove,
+			Ulimits:       info.Ulimits,
+			Sysctls:       info.Sysctls,
+		},
+		NetworkSettings: networkSettingsView{
+			IPAddress: info.IPAddress,
+			Ports:     info.PortMapping,
+			Networks:  map[string]endpointView{},
+		},
+		Mounts: []mountView{},
+	}
+	if len(info.Args) > 0 {
+		view.Path = info.Args[0]
+		view.Args = info.Args[1:]
+	}
+	if info.Resource != nil {
+		view.HostConfig.Memory = info.Resource.MemoryLimit
+		view.HostConfig.CpuShares = info.Resource.CpuShare
+		view.HostConfig.CpusetCpus = info.Resource.CpuSet
+	}
+	if info.Network != "" {
+		endpoint := endpointView{IPAddress: info.IPAddress}
+		if nw, ok := network.GetNetwork(info.Network); ok {
+			endpoint.Gateway = nw.IpRange.IP.String()
+		}
+		view.NetworkSettings.Networks[info.Network] = endpoint
+	}
+	if source, destination, ok := container.ParseVolume(info.Volume); ok {
+		view.Mounts = append(view.Mounts, mountView{Type: "bind", Source: source, Destination: destination})
+	}
+	return view
+}
+
+func newImageView(imageName string) (*imageView, error) {
+	config, err := container.LoadImageConfig(imageName)
+	if err != nil {
+		return nil, err
+	}
+	return &imageView{
+		Name:      imageName,
+		Tar:       container.ImageTarUrl(imageName),
+		Size:      container.ImageSize(imageName),
+		LayerSize: container.ImageLayerSize(imageName),
+		Config:    config,
+	}, nil
+}
+
+func newNetworkView(nw *network.Network, containers []*container.Info) *networkView {
+	view := &networkView{
+		Name:       nw.Name,
+		Driver:     nw.Driver,
+		Containers: map[string]endpointView{},
+	}
+	if nw.IpRange != nil {
+		subnet := *nw.IpRange
+		subnet.IP = subnet.IP.Mask(subnet.Mask)
+		view.Subnet = subnet.String()
+		view.Gateway = nw.IpRange.IP.String()
+	}
+	for _, item := range containers {
+		if item.Network == nw.Name && item.IPAddress != "" {
+			view.Containers[item.Id] = endpointView{IPAddress: item.IPAddress, Gateway: view.Gateway}
+		}
+	}
+	return view
+}
+
+// 数据卷以宿主机目录命名，返回使用该目录的容器，没有容器使用时返回 nil
+func newVolumeView(name string, containers []*container.Info) *volumeView {
+	var users []string
+	for _, item := range containers {
+		if source, _, ok := container.ParseVolume(item.Volume); ok && source == name {
+			users = append(users, item.Id)
+		}
+	}
+	if users == nil {
+		return nil
+	}
+	return &volumeView{Name: name, Mountpoint: name, Containers: users}
+}
@@ -11,8 +11,13 @@ import (
 )
 
 func logContainer(containerName string) {
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		log.Errorf("Get container %s info error %v", containerName, err)
+		return
+	}
 	// 找到对应文件夹的位置
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerInfo.Id)
 	logFileLocation := dirURL + container.LogFile
 	// 打开日志文件
 	file, err := os.Open(logFileLocation)
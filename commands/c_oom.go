@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/yourtion/ydocker/cgroups/subsystems"
+	"github.com/yourtion/ydocker/container"
+)
+
+// 当前进程正在监听 OOM 的容器，value 为停止监听的函数
+var (
+	oomMonitorsLock sync.Mutex
+	oomMonitors     = map[string]func(){}
+)
+
+// 监听容器 cgroup 中的 OOM，发生 OOM 时记录 OOMKilled 与 oom 事件
+func startOOMMonitor(containerInfo *container.Info) {
+	watcher, err := subsystems.WatchOOM(containerInfo.CgroupPath)
+	if err != nil {
+		log.Warnf("Watch oom of container %s error %v", containerInfo.Name, err)
+		return
+	}
+	containerId := containerInfo.Id
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range watcher.Events {
+			recordContainerOOM(containerId)
+		}
+	}()
+	oomMonitorsLock.Lock()
+	defer oomMonitorsLock.Unlock()
+	oomMonitors[containerId] = func() {
+		watcher.Close()
+		<-done
+	}
+}
+
+// 停止监听容器的 OOM，等待已经发生的 OOM 被记录
+func stopOOMMonitor(containerId string) {
+	oomMonitorsLock.Lock()
+	stop, ok := oomMonitors[containerId]
+	delete(oomMonitors, containerId)
+	oomMonitorsLock.Unlock()
+	if ok {
+		stop()
+	}
+}
+
+// 记录容器中的进程因为内存超过限制被杀死
+func recordContainerOOM(containerId string) {
+	latest, err := updateContainerInfo(containerId, func(info *container.Info) error {
+		info.OOMKilled = true
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Record container %s oom error %v", containerId, err)
+		return
+	}
+	log.Warnf("container %s is out of memory", latest.Name)
+	container.LogContainerEvent(latest, "oom", nil)
+}
+
+// 容器的 cgroup 中是否发生过 OOM，需要在释放 cgroup 之前调用
+func containerOOMKilled(containerInfo *container.Info) bool {
+	count, err := subsystems.OOMKillCount(containerInfo.CgroupPath)
+	return err == nil && count > 0
+}
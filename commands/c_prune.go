@@ -0,0 +1,230 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/yourtion/ydocker/container"
+	"github.com/yourtion/ydocker/network"
+)
+
+// 状态目录下不属于容器的目录
+var stateDirReserved = map[string]bool{
+	"network": true,
+}
+
+// 清理结果，dryRun 时只记录不删除
+type pruneReport struct {
+	dryRun    bool
+	deleted   map[string][]string
+	order     []string
+	reclaimed int64
+}
+
+func newPruneReport(dryRun bool) *pruneReport {
+	return &pruneReport{dryRun: dryRun, deleted: map[string][]string{}}
+}
+
+// 删除一项资源并记录释放的空间，remove 为 nil 或 dryRun 时只记录，删除失败时返回 false
+func (r *pruneReport) remove(kind, name string, size int64, remove func() error) bool {
+	if !r.dryRun && remove != nil {
+		if err := remove(); err != nil {
+			log.Errorf("Remove %s %s error %v", kind, name, err)
+			return false
+		}
+	}
+	if _, ok := r.deleted[kind]; !ok {
+		r.order = append(r.order, kind)
+	}
+	r.deleted[kind] = append(r.deleted[kind], name)
+	r.reclaimed += size
+	return true
+}
+
+func (r *pruneReport) print() {
+	title := "Deleted"
+	if r.dryRun {
+		title = "Would delete"
+	}
+	for _, kind := range r.order {
+		fmt.Printf("%s %s:\n", title, kind)
+		for _, name := range r.deleted[kind] {
+			fmt.Println(name)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Total reclaimed space: %s\n", humanSize(r.reclaimed))
+}
+
+// 统计镜像被容器引用的情况
+func usedImages(containers []*container.Info) map[string]bool {
+	used := map[string]bool{}
+	for _, item := range containers {
+		used[container.ImageKey(item.Image)] = true
+	}
+	return used
+}
+
+/*
+清理没有被容器使用的镜像
+	1. 默认只删除镜像解压后的只读层，下次运行时会从 tar 包重新解压
+	2. all 为 true 时同时删除镜像的 tar 包与配置
+*/
+func pruneImages(report *pruneReport, containers []*container.Info, all bool) error {
+	images, err := container.ListImages()
+	if err != nil {
+		return fmt.Errorf("list images error: %v", err)
+	}
+	used := usedImages(containers)
+	for _, image := range images {
+		if used[image] {
+			continue
+		}
+		image := image
+		if all {
+			size := container.ImageSize(image) + container.ImageLayerSize(image)
+			report.remove("Images", image, size, func() error {
+				return container.RemoveImage(image)
+			})
+		} else if size := container.ImageLayerSize(image); size > 0 {
+			report.remove("Image Layers", image, size, func() error {
+				return container.RemoveImageLayer(image)
+			})
+		}
+	}
+	return nil
+}
+
+// 清理已停止的容器、残留的容器目录与文件系统、未使用的网络和镜像，filter 只作用于容器
+func pruneSystem(report *pruneReport, all bool, filter container.Filter) error {
+	containers, err := getAllContainerInfos()
+	if err != nil {
+		return fmt.Errorf("get containers error: %v", err)
+	}
+	// 已停止的容器，删除失败的容器继续占用网络与镜像
+	var remaining []*container.Info
+	for _, item := range containers {
+		if !item.IsStopped() || !filter.Match(item) {
+			remaining = append(remaining, item)
+			continue
+		}
+		item := item
+		if !report.remove("Containers", item.Name, container.WriteLayerSize(item.Id), func() error {
+			return removeContainer(item.Id)
+		}) {
+			remaining = append(remaining, item)
+		}
+	}
+	// 网络、镜像等没有标签，指定过滤条件时只清理容器
+	if len(filter) > 0 {
+		return nil
+	}
+
+	// 有容器信息的目录与文件系统已经随容器处理，包括 dryRun 时没有真正删除的已停止容器
+	known := map[string]bool{}
+	for _, item := range containers {
+		known[item.Id] = true
+	}
+
+	// 没有容器信息的状态目录
+	dirURL := fmt.Sprintf(container.DefaultInfoLocation, "")
+	files, err := ioutil.ReadDir(dirURL)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, file := range files {
+		if !file.IsDir() || stateDirReserved[file.Name()] || known[file.Name()] {
+			continue
+		}
+		if _, err := os.Stat(dirURL + file.Name() + "/" + container.ConfigName); err == nil {
+			continue
+		}
+		path := dirURL + file.Name()
+		size, _ := container.DirSize(path)
+		report.remove("State Directories", path, size, func() error {
+			return os.RemoveAll(path)
+		})
+	}
+
+	// 没有容器信息的可写层与挂载点
+	workSpaces, err := container.ListWorkSpaces()
+	if err != nil {
+		return err
+	}
+	for _, name := range workSpaces {
+		if known[name] {
+			continue
+		}
+		name := name
+		report.remove("Container Layers", name, container.WriteLayerSize(name), func() error {
+			return container.RemoveStaleWorkSpace(name)
+		})
+	}
+
+	// 没有容器连接的网络
+	if err := network.Init(); err != nil {
+		return fmt.Errorf("init network error: %v", err)
+	}
+	usedNetworks := map[string]bool{}
+	for _, item := range remaining {
+		usedNetworks[item.Network] = true
+	}
+	for _, name := range network.ListNetworkNames() {
+		if usedNetworks[name] {
+			continue
+		}
+		name := name
+		report.remove("Networks", name, 0, func() error {
+			return network.DeleteNetwork(name)
+		})
+	}
+
+	return pruneImages(report, remaining, all)
+}
+
+// 统计镜像与容器占用的磁盘空间
+func systemDiskUsage() error {
+	containers, err := getAllContainerInfos()
+	if err != nil {
+		return fmt.Errorf("get containers error: %v", err)
+	}
+	images, err := container.ListImages()
+	if err != nil {
+		return fmt.Errorf("list images error: %v", err)
+	}
+	used := usedImages(containers)
+	var imageActive int
+	var imageSize, imageReclaimable int64
+	for _, image := range images {
+		size := container.ImageSize(image) + container.ImageLayerSize(image)
+		imageSize += size
+		if used[image] {
+			imageActive++
+		} else {
+			imageReclaimable += size
+		}
+	}
+	var containerActive int
+	var containerSize, containerReclaimable int64
+	for _, item := range containers {
+		size := container.WriteLayerSize(item.Id)
+		containerSize += size
+		if item.IsStopped() {
+			containerReclaimable += size
+		} else {
+			containerActive++
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	_, _ = fmt.Fprint(w, "TYPE\tTOTAL\tACTIVE\tSIZE\tRECLAIMABLE\n")
+	_, _ = fmt.Fprintf(w, "Images\t%d\t%d\t%s\t%s\n", len(images), imageActive,
+		humanSize(imageSize), humanSize(imageReclaimable))
+	_, _ = fmt.Fprintf(w, "Containers\t%d\t%d\t%s\t%s\n", len(containers), containerActive,
+		humanSize(containerSize), humanSize(containerReclaimable))
+	return w.Flush()
+}
@@ -1,55 +1,148 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"strings"
 	"text/tabwriter"
+	"text/template"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/yourtion/ydocker/container"
 )
 
-func listContainers() {
-	// 找到存储容器信息的路径 /var/run/ydocker
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, "")
-	dirURL = dirURL[:len(dirURL)-1]
-	// 卖取该文件夹下的所有文件
-	files, err := ioutil.ReadDir(dirURL)
+// ps 的输出选项
+type psOptions struct {
+	All     bool
+	Quiet   bool
+	Size    bool
+	Format  string
+	Filters []string
+}
+
+// ps --format 使用的容器信息，字段名与 docker ps 保持一致
+type psView struct {
+	ID           string
+	Names        string
+	Image        string
+	Command      string
+	CreatedAt    string
+	Status       string
+	State        string
+	Pid          string
+	RestartCount int
+	Ports        string
+	Networks     string
+	Labels       string
+	Size         string `json:",omitempty"`
+}
+
+/*
+列出容器：
+	1. 默认只列出运行中的容器，all 为 true 或者按状态过滤时列出所有容器
+	2. quiet 只输出容器 ID，format 为 json 时每行输出一个容器的 JSON，否则作为 Go 模板
+*/
+func listContainers(opts psOptions) error {
+	filter, err := container.ParseFilters(opts.Filters)
 	if err != nil {
-		log.Errorf("Read dir %s error %v", dirURL, err)
-		return
-	}
-	// 遍历该文件夹下的所有文件
-	var containers []*container.Info
-	for _, file := range files {
-		// 获取文件名
-		containerName := file.Name()
-		// 根据容器配置文件获取对应的信息，然后转换成容器信息的对象
-		tmpContainer, err := getContainerInfoByName(containerName)
-		if err != nil {
-			log.Errorf("Get container info error %v", err)
-			continue
+		return err
+	}
+	all := opts.All || filter.Has("status")
+	var tmpl *template.Template
+	if opts.Format != "" && opts.Format != "json" {
+		// 与 docker 一样允许在模板中使用 \t 对齐输出
+		if tmpl, err = parseFormat(strings.ReplaceAll(opts.Format, `\t`, "\t")); err != nil {
+			return err
 		}
-		containers = append(containers, tmpContainer)
+	}
+
+	containers, err := getAllContainerInfos()
+	if err != nil {
+		return fmt.Errorf("get containers error: %v", err)
 	}
 	// 使用 tabwriter.NewWriter 在控制台打印出容器信息（用于在控制台打印对齐的表格）
 	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
-	// 控制台输出的信息列
-	_, _ = fmt.Fprint(w, "ID\tNAME\tPID\tSTATUS\tCOMMAND\tCREATED\n")
+	if !opts.Quiet && opts.Format == "" {
+		// 控制台输出的信息列
+		header := "ID\tNAME\tPID\tSTATUS\tRESTARTS\tPORTS\tCOMMAND\tCREATED"
+		if opts.Size {
+			header += "\tSIZE"
+		}
+		_, _ = fmt.Fprintln(w, header)
+	}
 	for _, item := range containers {
-		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
-			item.Id,
-			item.Name,
-			item.Pid,
-			item.Status,
-			item.Command,
-			item.CreatedTime)
+		// 检查记录为运行中的容器是否已经不存在
+		item = reconcileContainerInfo(item)
+		if (!all && !item.IsActive()) || !filter.Match(item) {
+			continue
+		}
+		view := newPsView(item, opts.Size)
+		switch {
+		case opts.Quiet:
+			_, _ = fmt.Fprintln(w, view.ID)
+		case opts.Format == "json":
+			content, err := json.Marshal(view)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(w, string(content))
+		case tmpl != nil:
+			if err := tmpl.Execute(w, view); err != nil {
+				return fmt.Errorf("execute template error: %v", err)
+			}
+			_, _ = fmt.Fprintln(w)
+		default:
+			line := strings.Join([]string{view.ID, view.Names, view.Pid, view.Status,
+				fmt.Sprint(view.RestartCount), view.Ports, view.Command, view.CreatedAt}, "\t")
+			if opts.Size {
+				line += "\t" + view.Size
+			}
+			_, _ = fmt.Fprintln(w, line)
+		}
 	}
 	// 刷新标准输出流缓存区，将容器列表打印出来
 	if err := w.Flush(); err != nil {
 		log.Errorf("Flush error %v", err)
-		return
 	}
+	return nil
+}
+
+func newPsView(info *container.Info, size bool) *psView {
+	view := &psView{
+		ID:           container.ShortId(info.Id),
+		Names:        info.Name,
+		Image:        info.Image,
+		Command:      info.Command,
+		CreatedAt:    info.CreatedTime,
+		Status:       containerStatus(info),
+		State:        info.Status,
+		Pid:          info.Pid,
+		RestartCount: info.RestartCount,
+		Ports:        strings.Join(info.PortMapping, ","),
+		Networks:     info.Network,
+		Labels:       container.FormatLabels(info.Labels),
+	}
+	if size {
+		view.Size = humanSize(container.WriteLayerSize(info.Id))
+	}
+	return view
+}
+
+// 容器状态的展示，退出的容器附带退出码与是否因为 OOM 被杀死，运行中的容器附带健康状态
+func containerStatus(info *container.Info) string {
+	switch info.Status {
+	case container.Exit:
+		if info.OOMKilled {
+			return fmt.Sprintf("%s (%d) OOMKilled", info.Status, info.ExitCode)
+		}
+		return fmt.Sprintf("%s (%d)", info.Status, info.ExitCode)
+	case container.RUNNING:
+		if info.Health != nil {
+			return fmt.Sprintf("%s since %s (%s)", info.Status, info.StartedTime, info.Health.Status)
+		}
+		return fmt.Sprintf("%s since %s", info.Status, info.StartedTime)
+	}
+	return info.Status
 }
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/yourtion/ydocker/container"
+)
+
+// 容器名的格式，与 docker 保持一致
+var validContainerName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// 检查容器名是否合法并且没有被其他容器使用，调用方需要持有 lockStateDir 的锁直到保存容器名
+func checkContainerName(containerName string) error {
+	if !validContainerName.MatchString(containerName) {
+		return fmt.Errorf("invalid container name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", containerName)
+	}
+	containers, err := getAllContainerInfos()
+	if err != nil {
+		return err
+	}
+	for _, item := range containers {
+		if item.Name == containerName || item.Id == containerName {
+			return fmt.Errorf("container name %s is already in use by container %s", containerName, item.Id)
+		}
+	}
+	return nil
+}
+
+/*
+重命名容器，容器的目录都以容器 ID 命名，只需要修改 config.json 中的容器名，
+运行中的容器也可以重命名，父进程之后更新容器信息时会读取新的容器名
+*/
+func renameContainer(oldName, newName string) error {
+	containerInfo, err := getContainerInfoByName(oldName)
+	if err != nil {
+		return err
+	}
+	if containerInfo.Name == newName {
+		return fmt.Errorf("container %s already has name %s", oldName, newName)
+	}
+	unlock, err := lockStateDir()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkContainerName(newName); err != nil {
+		return err
+	}
+	latest, err := updateContainerInfo(containerInfo.Id, func(info *container.Info) error {
+		info.Name = newName
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	container.LogContainerEvent(latest, "rename", map[string]string{"oldName": containerInfo.Name})
+	return nil
+}
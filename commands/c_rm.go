@@ -3,29 +3,70 @@ package commands
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/yourtion/ydocker/container"
 )
 
-func removeContainer(containerName string) {
+// 删除多个容器，只指定过滤条件时删除所有符合条件的已停止容器
+func removeContainers(containerNames, filters []string) error {
+	ids, err := selectContainers(containerNames, filters, func(info *container.Info) bool {
+		return info.IsStopped()
+	})
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, id := range ids {
+		if err := removeContainer(id); err != nil {
+			log.Errorf("Remove container %s error %v", id, err)
+			failed = append(failed, id)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove containers: %v", failed)
+	}
+	return nil
+}
+
+func removeContainer(containerName string) error {
 	// 根据容器名获取容器对应的信息
 	containerInfo, err := getContainerInfoByName(containerName)
 	if err != nil {
-		log.Errorf("Get container %s info error %v", containerName, err)
-		return
+		return fmt.Errorf("get container %s info error: %v", containerName, err)
 	}
+	containerInfo = reconcileContainerInfo(containerInfo)
 	// 只删除处于停止状态的容器
-	if containerInfo.Status != container.STOP {
-		log.Errorf("Couldn't remove running container")
-		return
+	if !containerInfo.IsStopped() {
+		return fmt.Errorf("couldn't remove running container %s", containerName)
+	}
+	// create 创建的容器还有等待 start 的 init 进程，杀死后由 shim 记录退出并清理资源
+	if containerInfo.IsPending() {
+		if pid, err := strconv.Atoi(containerInfo.Pid); err == nil {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+		}
+		if !waitContainerStopped(containerInfo.Id, 10*time.Second) {
+			return fmt.Errorf("couldn't remove created container %s, init process did not exit", containerName)
+		}
+		if containerInfo, err = readContainerInfo(containerInfo.Id); err != nil {
+			return fmt.Errorf("get container %s info error: %v", containerName, err)
+		}
+	}
+	// dead 的容器没有经过正常的退出流程，需要先释放 cgroup 与网络等资源
+	if containerInfo.Status == container.DEAD {
+		cleanupContainer(containerInfo)
 	}
 	// 找到对应存储容器信息的文件路径
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerInfo.Id)
 	// 将所有信息包括子目录都移除
 	if err := os.RemoveAll(dirURL); err != nil {
-		log.Errorf("Remove file %s error %v", dirURL, err)
+		return fmt.Errorf("remove file %s error: %v", dirURL, err)
 	}
-	container.DeleteWorkSpace(containerInfo.Volume, containerName)
+	container.DeleteWorkSpace(containerInfo.Volume, containerInfo.Id)
+	container.LogContainerEvent(containerInfo, "destroy", nil)
+	return nil
 }
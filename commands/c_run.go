@@ -1,16 +1,19 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/exec"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/yourtion/ydocker/cgroups"
-	"github.com/yourtion/ydocker/cgroups/subsystems"
 	"github.com/yourtion/ydocker/container"
 	"github.com/yourtion/ydocker/network"
 )
@@ -18,74 +21,258 @@ import (
 /*
 这里的 Start 方法是真正开始前面创建好的 commands 的调用，它首先会 clone 出来一个 namespace 隔离的进程，
 然后在子进程中，调用 /proc/self/exe，也就是调用自己，发送 init 参数，调用我们写的 init 方法，去初始化容器的一些资源。
+	1. 指定 -ti 时由当前进程作为容器的父进程，等待容器退出后记录退出状态并清理资源
+	2. 后台运行时由 shim 进程作为容器的父进程，当前进程在容器启动后直接返回
 */
-func run(tty bool, comArray []string, res *subsystems.ResourceConfig, containerName, volume, imageName string,
-	envSlice []string, nw string, portMapping []string) {
-	containerId := randStringBytes(10)
-	if containerName == "" {
-		containerName = containerId
+func run(tty bool, containerInfo *container.Info) error {
+	if err := prepareContainerInfo(containerInfo); err != nil {
+		return err
+	}
+	if !tty {
+		return startShim(containerInfo, false)
+	}
+	parent, err := startContainer(containerInfo, true)
+	if err != nil {
+		// 启动失败时不会进入 superviseContainer，需要在这里删除 --rm 的容器
+		autoRemoveContainer(containerInfo)
+		return err
 	}
+	// 终端中的 Ctrl-C 等信号由容器进程处理，当前进程需要等待容器退出后完成清理
+	signal.Ignore(syscall.SIGINT, syscall.SIGQUIT)
+	os.Exit(superviseContainer(parent, containerInfo, true))
+	return nil
+}
 
-	parent, writePipe := container.NewParentProcess(tty, containerName, volume, imageName, envSlice)
-	if parent == nil {
-		log.Errorf("New parent process error")
-		return
+// 生成容器 ID 并记录容器信息，shim 与 start 都根据记录的信息启动容器
+func prepareContainerInfo(containerInfo *container.Info) error {
+	containerId, err := container.GenerateId()
+	if err != nil {
+		return err
 	}
-	if err := parent.Start(); err != nil {
-		log.Error(err)
+	if containerInfo.Name == "" {
+		containerInfo.Name = container.ShortId(containerId)
+	}
+	unlock, err := lockStateDir()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkContainerName(containerInfo.Name); err != nil {
+		return err
+	}
+	containerInfo.Id = containerId
+	if containerInfo.Hostname == "" {
+		containerInfo.Hostname = container.ShortId(containerId)
+	}
+	containerInfo.Command = strings.Join(containerInfo.Args, " ")
+	containerInfo.CreatedTime = time.Now().Format(container.TimeFormat)
+	containerInfo.Status = container.CREATED
+	containerInfo.CgroupPath = container.CGroupName + "-" + containerId
+	if err := recordContainerInfo(containerInfo); err != nil {
+		return fmt.Errorf("record container info error: %v", err)
+	}
+	container.LogContainerEvent(containerInfo, "create", nil)
+	return nil
+}
+
+// 只创建容器，由 shim 进程准备好容器后等待 start 命令
+func create(containerInfo *container.Info) error {
+	if err := prepareContainerInfo(containerInfo); err != nil {
+		return err
 	}
+	return startShim(containerInfo, true)
+}
+
+// 创建容器进程，设置资源限制与网络后发送用户命令，返回容器的父进程
+func startContainer(containerInfo *container.Info, tty bool) (*exec.Cmd, error) {
+	parent, writePipe, err := createContainer(containerInfo, tty, container.RUNNING)
+	if err != nil {
+		return nil, err
+	}
+	// 发送用户命令
+	sendInitCommand(containerInfo, writePipe)
+	return parent, nil
+}
 
-	// 记录容器信息
-	if err := recordContainerInfo(parent.Process.Pid, comArray, containerName, containerId, volume); err != nil {
-		log.Errorf("Record container info error %v", err)
-		return
+/*
+创建容器进程并设置资源限制与网络，容器的 init 进程阻塞在读取用户命令的管道上，
+返回容器的父进程与用于发送用户命令的管道，status 为记录的容器状态
+*/
+func createContainer(containerInfo *container.Info, tty bool, status string) (*exec.Cmd, *os.File, error) {
+	parent, writePipe := container.NewParentProcess(tty, containerInfo.Id, containerInfo.Volume,
+		containerInfo.Image, containerInfo.Env)
+	if parent == nil {
+		return nil, nil, fmt.Errorf("new parent process error")
 	}
+	err := parent.Start()
+	container.CloseChildFiles(parent)
+	if err != nil {
+		_ = writePipe.Close()
+		container.UnmountWorkSpace(containerInfo.Volume, containerInfo.Id)
+		return nil, nil, err
+	}
+	if err := setupContainer(containerInfo, parent.Process.Pid, status); err != nil {
+		// 容器进程还阻塞在读取命令的管道上，直接杀掉并走退出流程清理资源
+		_ = parent.Process.Kill()
+		_ = writePipe.Close()
+		waitContainer(parent, containerInfo, false)
+		return nil, nil, err
+	}
+	return parent, writePipe, nil
+}
 
+// 设置容器的资源限制与网络，并记录容器的运行信息
+func setupContainer(containerInfo *container.Info, pid int, status string) error {
 	// 创建 cgroup manager，并通过调用 set 和 apply 设置资源限制并使限制在容器上生效
-	cgroupManager := cgroups.NewCgroupManager(container.CGroupName)
+	cgroupManager := cgroups.NewCgroupManager(containerInfo.CgroupPath)
 	// 设置资源限制
-	if err := cgroupManager.Set(res); err != nil {
-		log.Error(err)
+	if containerInfo.Resource != nil {
+		if err := cgroupManager.Set(containerInfo.Resource); err != nil {
+			log.Error(err)
+		}
 	}
 	// 将容器进程加入到各个 subsystem 挂载对应的 cgroup 中
-	if err := cgroupManager.Apply(parent.Process.Pid); err != nil {
+	if err := cgroupManager.Apply(pid); err != nil {
 		log.Error(err)
 	}
+	// 在用户命令开始执行之前监听 OOM
+	startOOMMonitor(containerInfo)
 
-	if nw != "" {
+	if containerInfo.Network != "" {
 		// config container network
 		if err := network.Init(); err != nil {
 			log.Errorf("Error Init Network %v", err)
 		}
-		containerInfo := &container.Info{
-			Id:          containerId,
-			Pid:         strconv.Itoa(parent.Process.Pid),
-			Name:        containerName,
-			PortMapping: portMapping,
+		if err := network.Connect(containerInfo.Network, containerInfo); err != nil {
+			return fmt.Errorf("connect network %s error: %v", containerInfo.Network, err)
+		}
+	}
+	// 记录容器的运行信息，当前进程作为容器的父进程负责记录容器的退出
+	ipAddress := containerInfo.IPAddress
+	latest, err := updateContainerInfo(containerInfo.Id, func(info *container.Info) error {
+		if info.Status != status {
+			if err := info.SetStatus(status); err != nil {
+				return err
+			}
 		}
-		if err := network.Connect(nw, containerInfo); err != nil {
-			log.Errorf("Error Connect Network %v", err)
-			return
+		info.Pid = strconv.Itoa(pid)
+		info.MonitorPid = os.Getpid()
+		info.IPAddress = ipAddress
+		info.ExitCode = 0
+		info.OOMKilled = false
+		info.FinishedTime = ""
+		if status == container.RUNNING {
+			info.StartedTime = time.Now().Format(container.TimeFormat)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*containerInfo = *latest
+	if status == container.RUNNING {
+		container.LogContainerEvent(containerInfo, "start", nil)
 	}
+	return nil
+}
 
-	// 发送用户命令
-	sendInitCommand(comArray, writePipe)
-	if tty {
-		if err := parent.Wait(); err != nil {
-			log.Error(err)
+/*
+等待容器进程退出，记录退出码并清理容器占用的资源
+	1. restart 为 true 时按照重启策略判断是否需要重启，需要重启的容器进入 restarting 状态
+	2. 否则容器进入 exited 状态
+*/
+func waitContainer(parent *exec.Cmd, containerInfo *container.Info, restart bool) int {
+	if err := parent.Wait(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			log.Errorf("Wait container %s error %v", containerInfo.Name, err)
+		}
+	}
+	exitCode := exitCodeOf(parent.ProcessState)
+	// 释放 cgroup 之前停止 OOM 监听，并检查是否发生过没有被监听到的 OOM
+	stopOOMMonitor(containerInfo.Id)
+	oomKilled := containerOOMKilled(containerInfo)
+	cleanupContainer(containerInfo)
+	oomRecorded := true
+	latest, err := updateContainerInfo(containerInfo.Id, func(info *container.Info) error {
+		oomRecorded = info.OOMKilled
+		if oomKilled {
+			info.OOMKilled = true
+		}
+		// 在同一次更新中判断是否重启，避免与 stop 写入的 ManualStop 产生竞争
+		if restart && info.RestartPolicy.ShouldRestart(exitCode, info.RestartCount, info.ManualStop) {
+			if err := info.SetStatus(container.RESTARTING); err != nil {
+				return err
+			}
+			info.RestartCount++
+		} else {
+			if err := info.SetStatus(container.Exit); err != nil {
+				return err
+			}
+			info.MonitorPid = 0
+		}
+		info.Pid = ""
+		info.IPAddress = ""
+		info.ExitCode = exitCode
+		info.FinishedTime = time.Now().Format(container.TimeFormat)
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Record container %s exit error %v", containerInfo.Name, err)
+		return exitCode
+	}
+	*containerInfo = *latest
+	if oomKilled && !oomRecorded {
+		container.LogContainerEvent(containerInfo, "oom", nil)
+	}
+	container.LogContainerEvent(containerInfo, "die", map[string]string{"exitCode": strconv.Itoa(exitCode)})
+	return exitCode
+}
+
+// 进程的退出码，被信号杀死时与 shell 一样使用 128 + 信号值
+func exitCodeOf(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
+
+// 容器退出后释放 cgroup、网络与挂载的文件系统，可写层保留到容器被删除
+func cleanupContainer(containerInfo *container.Info) {
+	if err := cgroups.NewCgroupManager(containerInfo.CgroupPath).Destroy(); err != nil {
+		log.Errorf("Destroy cgroup %s error %v", containerInfo.CgroupPath, err)
+	}
+	if containerInfo.Network != "" && containerInfo.IPAddress != "" {
+		if err := network.Init(); err != nil {
+			log.Errorf("Error Init Network %v", err)
+		}
+		if err := network.Disconnect(containerInfo.Network, containerInfo); err != nil {
+			log.Errorf("Disconnect network %s error %v", containerInfo.Network, err)
 		}
-		deleteContainerInfo(containerName)
-		container.DeleteWorkSpace(volume, containerName)
-		_ = cgroupManager.Destroy()
-		os.Exit(0)
 	}
+	container.UnmountWorkSpace(containerInfo.Volume, containerInfo.Id)
 }
 
-func sendInitCommand(comArray []string, writePipe *os.File) {
-	command := strings.Join(comArray, " ")
-	log.Infof(`commands all is "%s"`, command)
-	if _, err := writePipe.WriteString(command); err != nil {
+// 通过管道把用户命令与 init 进程的配置发送给容器，此时容器已经连接网络，/etc/hosts 中可以使用分配的 IP
+func sendInitCommand(containerInfo *container.Info, writePipe *os.File) {
+	log.Infof(`commands all is "%s"`, strings.Join(containerInfo.Args, " "))
+	initConfig := &container.InitConfig{
+		Args:       containerInfo.Args,
+		Ulimits:    containerInfo.Ulimits,
+		Sysctls:    containerInfo.Sysctls,
+		Hostname:   containerInfo.Hostname,
+		Domainname: containerInfo.Domainname,
+	}
+	if containerInfo.Hostname != "" {
+		files, err := container.WriteHostsFiles(containerInfo)
+		if err != nil {
+			log.Errorf("Write hosts files of container %s error %v", containerInfo.Name, err)
+		}
+		initConfig.Files = files
+	}
+	if err := json.NewEncoder(writePipe).Encode(initConfig); err != nil {
 		log.Error(err)
 	}
 	if err := writePipe.Close(); err != nil {
@@ -94,27 +281,15 @@ func sendInitCommand(comArray []string, writePipe *os.File) {
 }
 
 // 记录容器信息
-func recordContainerInfo(containerPID int, commandArray []string, containerName, id, volume string) error {
-	createTime := time.Now().Format("2006-01-02 15:04:05")
-	command := strings.Join(commandArray, "")
-	// 生成容器信息的结构体实例
-	containerInfo := &container.Info{
-		Id:          id,
-		Pid:         strconv.Itoa(containerPID),
-		Command:     command,
-		CreatedTime: createTime,
-		Status:      container.RUNNING,
-		Name:        containerName,
-		Volume:      volume,
-	}
+func recordContainerInfo(containerInfo *container.Info) error {
 	// 拼凑存储容器信息的路径
-	dirUrl := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+	dirUrl := fmt.Sprintf(container.DefaultInfoLocation, containerInfo.Id)
 	// 如果该路径不存在，就级联地全部创建
 	if err := os.MkdirAll(dirUrl, 0622); err != nil {
 		log.Errorf("Mkdir error %s error %v", dirUrl, err)
 		return err
 	}
-	if err := writeContainerInfoByName(containerName, containerInfo); err != nil {
+	if err := writeContainerInfo(containerInfo); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,266 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/yourtion/ydocker/container"
+)
+
+// shim 启动容器成功后通过管道返回的消息
+const shimReady = "ok"
+
+/*
+启动后台容器的 shim 进程：
+	1. shim 进程使用新的 session 与调用者分离，作为容器 init 进程的父进程
+	2. 通过 fd 3 的管道等待 shim 返回容器的启动结果
+	3. waitStart 为 true 时 shim 创建好容器后等待 start 命令再运行用户命令
+*/
+func startShim(containerInfo *container.Info, waitStart bool) error {
+	readPipe, writePipe, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("new pipe error: %v", err)
+	}
+	defer readPipe.Close()
+	// shim 自身的日志写入容器目录的 shim.log
+	logPath := fmt.Sprintf(container.DefaultInfoLocation, containerInfo.Id) + container.ShimLogFile
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		_ = writePipe.Close()
+		return fmt.Errorf("create shim log %s error: %v", logPath, err)
+	}
+	defer logFile.Close()
+
+	args := []string{"shim"}
+	if waitStart {
+		args = append(args, "--wait-start")
+	}
+	cmd := selfCommand(append(args, containerInfo.Id)...)
+	cmd.Dir = "/"
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.ExtraFiles = []*os.File{writePipe}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	err = cmd.Start()
+	_ = writePipe.Close()
+	if err != nil {
+		return fmt.Errorf("start shim error: %v", err)
+	}
+	// shim 与当前进程分离，不需要等待它退出
+	_ = cmd.Process.Release()
+
+	msg, _ := bufio.NewReader(readPipe).ReadString('\n')
+	msg = strings.TrimSpace(msg)
+	if msg != shimReady {
+		if msg == "" {
+			msg = "shim exited unexpectedly, see " + logPath
+		}
+		return fmt.Errorf("start container %s error: %s", containerInfo.Name, msg)
+	}
+	fmt.Println(containerInfo.Id)
+	return nil
+}
+
+// shim 进程：启动容器并等待其退出，记录退出状态后清理资源
+func shimContainer(containerId string, waitStart bool) error {
+	// fd 3 是用于返回启动结果的管道，不能被之后启动的进程继承
+	status := os.NewFile(uintptr(3), "status")
+	syscall.CloseOnExec(3)
+
+	containerInfo, err := readContainerInfo(containerId)
+	if err != nil {
+		_, _ = fmt.Fprintln(status, err)
+		return err
+	}
+	if waitStart {
+		return shimCreatedContainer(containerInfo, status)
+	}
+	parent, err := startContainer(containerInfo, false)
+	if err != nil {
+		// 启动失败时不会进入 superviseContainer，需要在返回结果之前删除 --rm 的容器
+		autoRemoveContainer(containerInfo)
+		_, _ = fmt.Fprintln(status, err)
+		return err
+	}
+	_, _ = fmt.Fprintln(status, shimReady)
+	_ = status.Close()
+
+	superviseContainer(parent, containerInfo, false)
+	return nil
+}
+
+/*
+create 创建的容器：
+	1. 创建容器进程并设置好资源限制与网络，容器保持 created 状态，init 进程阻塞在读取用户命令的管道上
+	2. 创建 exec.fifo 并等待 start 命令打开 FIFO 写入，之后发送用户命令
+	3. 等待期间容器进程被杀死时记录容器退出
+*/
+func shimCreatedContainer(containerInfo *container.Info, status *os.File) error {
+	fifoPath := fmt.Sprintf(container.DefaultInfoLocation, containerInfo.Id) + container.ExecFifo
+	_ = os.Remove(fifoPath)
+	if err := syscall.Mkfifo(fifoPath, 0600); err != nil {
+		err = fmt.Errorf("create fifo %s error: %v", fifoPath, err)
+		_, _ = fmt.Fprintln(status, err)
+		return err
+	}
+	defer os.Remove(fifoPath)
+	// 在返回 create 的结果之前打开 FIFO，之后立即执行的 start 才能以非阻塞方式打开写入端，
+	// 以读写方式打开不会阻塞，读取会阻塞到 start 命令写入
+	fifo, err := os.OpenFile(fifoPath, os.O_RDWR, 0)
+	if err != nil {
+		err = fmt.Errorf("open fifo %s error: %v", fifoPath, err)
+		_, _ = fmt.Fprintln(status, err)
+		return err
+	}
+	defer fifo.Close()
+	parent, writePipe, err := createContainer(containerInfo, false, container.CREATED)
+	if err != nil {
+		autoRemoveContainer(containerInfo)
+		_, _ = fmt.Fprintln(status, err)
+		return err
+	}
+	_, _ = fmt.Fprintln(status, shimReady)
+	_ = status.Close()
+
+	started := make(chan error, 1)
+	go func() {
+		_, err := fifo.Read(make([]byte, 1))
+		started <- err
+	}()
+	ticker := time.NewTicker(stopPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-started:
+			if err != nil {
+				log.Errorf("Wait start of container %s error %v", containerInfo.Name, err)
+			}
+			if _, err := updateContainerInfo(containerInfo.Id, func(info *container.Info) error {
+				info.StartedTime = time.Now().Format(container.TimeFormat)
+				return info.SetStatus(container.RUNNING)
+			}); err != nil {
+				log.Errorf("Record container %s start error %v", containerInfo.Name, err)
+			}
+			containerInfo.Status = container.RUNNING
+			container.LogContainerEvent(containerInfo, "start", nil)
+			sendInitCommand(containerInfo, writePipe)
+			superviseContainer(parent, containerInfo, false)
+			return nil
+		case <-ticker.C:
+			// 容器进程在 start 之前被杀死，退出的进程在被回收前是僵尸进程
+			if !isProcessAlive(parent.Process.Pid) {
+				_ = writePipe.Close()
+				exitCode := waitContainer(parent, containerInfo, false)
+				log.Infof("container %s exited with code %d before start", containerInfo.Name, exitCode)
+				autoRemoveContainer(containerInfo)
+				return nil
+			}
+		}
+	}
+}
+
+// 通知 shim 启动 create 创建的容器
+func signalContainerStart(containerInfo *container.Info) error {
+	fifoPath := fmt.Sprintf(container.DefaultInfoLocation, containerInfo.Id) + container.ExecFifo
+	// 非阻塞打开，shim 没有在等待时返回 ENXIO 而不是一直阻塞
+	fifo, err := os.OpenFile(fifoPath, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("container %s is not waiting for start: %v", containerInfo.Name, err)
+	}
+	defer fifo.Close()
+	if _, err := fifo.Write([]byte{0}); err != nil {
+		return fmt.Errorf("start container %s error: %v", containerInfo.Name, err)
+	}
+	return nil
+}
+
+// 运行超过该时间后退出的容器，重启等待时间重新从最小值开始计算
+const restartResetDuration = 10 * time.Second
+
+/*
+监控容器进程，容器退出后按照重启策略重新启动容器，返回容器最后一次退出的退出码
+	1. 连续重启之间的等待时间指数增长，容器运行时间超过 restartResetDuration 后重置
+	2. 等待期间容器处于 restarting 状态，被 stop 手动停止时结束等待
+	3. 指定了 --rm 的容器在最终退出后被删除
+	4. 容器运行期间按照健康检查配置执行检查
+*/
+func superviseContainer(parent *exec.Cmd, containerInfo *container.Info, tty bool) int {
+	defer autoRemoveContainer(containerInfo)
+	attempt := 0
+	for {
+		startedAt := time.Now()
+		stopHealthMonitor := startHealthMonitor(containerInfo)
+		exitCode := waitContainer(parent, containerInfo, true)
+		stopHealthMonitor()
+		log.Infof("container %s exited with code %d", containerInfo.Name, exitCode)
+		if containerInfo.Status != container.RESTARTING {
+			return exitCode
+		}
+		if time.Since(startedAt) > restartResetDuration {
+			attempt = 0
+		}
+		delay := container.RestartDelay(attempt)
+		attempt++
+		log.Infof("restart container %s in %s (policy %s, restart count %d)",
+			containerInfo.Name, delay, containerInfo.RestartPolicy, containerInfo.RestartCount)
+		if !waitRestartDelay(containerInfo, delay) {
+			return exitCode
+		}
+		var err error
+		if parent, err = startContainer(containerInfo, tty); err != nil {
+			log.Errorf("Restart container %s error %v", containerInfo.Name, err)
+			markContainerExited(containerInfo.Id)
+			return exitCode
+		}
+	}
+}
+
+// 等待下一次重启，期间容器被手动停止时记录容器退出并返回 false
+func waitRestartDelay(containerInfo *container.Info, delay time.Duration) bool {
+	deadline := time.Now().Add(delay)
+	for time.Now().Before(deadline) {
+		time.Sleep(stopPollInterval)
+		info, err := readContainerInfo(containerInfo.Id)
+		if err != nil {
+			log.Errorf("Get container %s info error %v", containerInfo.Name, err)
+			return false
+		}
+		if info.ManualStop {
+			markContainerExited(containerInfo.Id)
+			return false
+		}
+	}
+	return true
+}
+
+// 将等待重启的容器标记为退出
+func markContainerExited(containerId string) {
+	_, err := updateContainerInfo(containerId, func(info *container.Info) error {
+		if info.Status != container.RESTARTING {
+			return nil
+		}
+		info.MonitorPid = 0
+		return info.SetStatus(container.Exit)
+	})
+	if err != nil {
+		log.Errorf("Record container %s exit error %v", containerId, err)
+	}
+}
+
+// 删除指定了 --rm 的容器，容器退出时已经释放了 cgroup、网络与挂载点，这里删除可写层与容器信息
+func autoRemoveContainer(containerInfo *container.Info) {
+	if !containerInfo.AutoRemove {
+		return
+	}
+	log.Infof("remove container %s", containerInfo.Name)
+	container.DeleteWorkSpace(containerInfo.Volume, containerInfo.Id)
+	deleteContainerInfo(containerInfo.Id)
+	container.LogContainerEvent(containerInfo, "destroy", nil)
+}
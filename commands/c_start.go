@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/yourtion/ydocker/container"
+)
+
+/*
+重新启动已经停止的容器，使用记录的命令、资源限制、网络与数据卷，在原来的可写层上运行
+	1. 默认与 run -d 一样由 shim 进程在后台运行容器
+	2. attach 为 true 时由当前进程运行容器，容器的输出直接打印到终端
+	3. create 创建的容器已经由 shim 进程准备好，通知 shim 运行用户命令即可，attach 时跟踪容器日志
+*/
+func startStoppedContainer(containerName string, attach bool) error {
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return fmt.Errorf("get container %s info error: %v", containerName, err)
+	}
+	containerInfo = reconcileContainerInfo(containerInfo)
+	if containerInfo.IsActive() {
+		return fmt.Errorf("container %s is already %s", containerName, containerInfo.Status)
+	}
+	if containerInfo.Status == container.DEAD {
+		return fmt.Errorf("container %s is dead, remove it instead", containerName)
+	}
+	// 手动启动的容器重新按照重启策略运行
+	containerInfo, err = updateContainerInfo(containerInfo.Id, func(info *container.Info) error {
+		info.ManualStop = false
+		info.RestartCount = 0
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if containerInfo.IsPending() {
+		if err := signalContainerStart(containerInfo); err != nil {
+			return err
+		}
+		if !attach {
+			fmt.Println(containerInfo.Id)
+			return nil
+		}
+		exitCode, err := followContainerLog(containerInfo.Id)
+		if err != nil {
+			return err
+		}
+		os.Exit(exitCode)
+	}
+	if !attach {
+		return startShim(containerInfo, false)
+	}
+	parent, err := startContainer(containerInfo, true)
+	if err != nil {
+		// 启动失败时不会进入 superviseContainer，需要在这里删除 --rm 的容器
+		autoRemoveContainer(containerInfo)
+		return err
+	}
+	signal.Ignore(syscall.SIGINT, syscall.SIGQUIT)
+	os.Exit(superviseContainer(parent, containerInfo, true))
+	return nil
+}
+
+// 停止容器后重新在后台启动，停止的容器直接启动
+func restartContainer(containerName string, timeout time.Duration) error {
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return fmt.Errorf("get container %s info error: %v", containerName, err)
+	}
+	if err := terminateContainer(reconcileContainerInfo(containerInfo), timeout); err != nil {
+		return err
+	}
+	if err := startStoppedContainer(containerInfo.Id, false); err != nil {
+		return err
+	}
+	container.LogContainerEvent(containerInfo, "restart", nil)
+	return nil
+}
+
+// 持续输出容器日志直到容器退出，返回容器的退出码
+func followContainerLog(containerId string) (int, error) {
+	logPath := fmt.Sprintf(container.DefaultInfoLocation, containerId) + container.LogFile
+	file, err := os.Open(logPath)
+	if err != nil {
+		return 0, fmt.Errorf("open log %s error: %v", logPath, err)
+	}
+	defer file.Close()
+	for {
+		containerInfo, err := readContainerInfo(containerId)
+		if err != nil {
+			return 0, err
+		}
+		containerInfo = reconcileContainerInfo(containerInfo)
+		// 先读取状态再输出日志，保证容器退出前写入的日志都被输出
+		if _, err := io.Copy(os.Stdout, file); err != nil {
+			return 0, err
+		}
+		if !containerInfo.IsActive() && !containerInfo.IsPending() {
+			return containerInfo.ExitCode, nil
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
@@ -1,40 +1,156 @@
 package commands
 
 import (
+	"fmt"
 	"strconv"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
-	"github.com/yourtion/ydocker/cgroups"
 	"github.com/yourtion/ydocker/container"
 )
 
-func stopContainer(containerName string) {
+// 等待容器退出时轮询容器状态的间隔
+const stopPollInterval = 100 * time.Millisecond
+
+// 停止多个容器，只指定过滤条件时停止所有符合条件的运行中容器
+func stopContainers(containerNames, filters []string, timeout time.Duration) error {
+	ids, err := selectContainers(containerNames, filters, func(info *container.Info) bool {
+		return info.IsActive() || info.IsPending()
+	})
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, id := range ids {
+		if err := stopContainer(id, timeout); err != nil {
+			log.Errorf("Stop container %s error %v", container.ShortId(id), err)
+			failed = append(failed, container.ShortId(id))
+			continue
+		}
+		fmt.Println(container.ShortId(id))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to stop containers: %v", failed)
+	}
+	return nil
+}
+
+// 停止容器，超过 timeout 未退出时强制杀死
+func stopContainer(containerName string, timeout time.Duration) error {
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return fmt.Errorf("get container %s info error: %v", containerName, err)
+	}
+	containerInfo = reconcileContainerInfo(containerInfo)
+	if !containerInfo.IsActive() && !containerInfo.IsPending() {
+		return fmt.Errorf("container %s is not running, status '%s'", containerName, containerInfo.Status)
+	}
+	if err := terminateContainer(containerInfo, timeout); err != nil {
+		return err
+	}
+	container.LogContainerEvent(containerInfo, "stop", nil)
+	return nil
+}
+
+// 向容器的 init 进程发送信号，不修改记录的容器状态，容器退出由父进程记录
+func killContainer(containerName, signal string) error {
+	sig, err := container.ParseSignal(signal)
+	if err != nil {
+		return err
+	}
 	containerInfo, err := getContainerInfoByName(containerName)
 	if err != nil {
-		log.Errorf("Get contaienr info by name %s error %v", containerName, err)
-		return
+		return fmt.Errorf("get container %s info error: %v", containerName, err)
+	}
+	containerInfo = reconcileContainerInfo(containerInfo)
+	if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+		return fmt.Errorf("container %s is not running, status '%s'", containerName, containerInfo.Status)
+	}
+	pid, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil {
+		return fmt.Errorf("invalid pid '%s' of container %s", containerInfo.Pid, containerName)
+	}
+	if err := syscall.Kill(pid, sig); err != nil {
+		return fmt.Errorf("send signal %s to container %s error: %v", signal, containerName, err)
+	}
+	container.LogContainerEvent(containerInfo, "kill", map[string]string{"signal": strconv.Itoa(int(sig))})
+	return nil
+}
+
+/*
+停止运行中的容器：
+	1. 标记容器被手动停止，避免父进程按照重启策略重启容器
+	2. 发送容器的停止信号（默认为 SIGTERM），等待父进程记录容器退出
+	3. 超过 timeout 仍未退出时发送 SIGKILL 强制杀死容器
+*/
+func terminateContainer(containerInfo *container.Info, timeout time.Duration) error {
+	if !containerInfo.IsActive() && !containerInfo.IsPending() {
+		return nil
+	}
+	containerInfo, err := markManualStop(containerInfo.Id)
+	if err != nil {
+		return err
+	}
+	// 等待重启的容器没有运行中的进程，父进程发现手动停止后会记录容器退出
+	if containerInfo.Pid == "" {
+		if !waitContainerStopped(containerInfo.Id, timeout) {
+			return fmt.Errorf("container %s did not stop", containerInfo.Name)
+		}
+		return nil
+	}
+	pid, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil {
+		return fmt.Errorf("invalid pid '%s' of container %s", containerInfo.Pid, containerInfo.Name)
 	}
-	if containerInfo.Status != container.RUNNING || containerInfo.Pid == " " {
-		log.Errorf("Contaienr status '%s' is not RUNNING pid: '%s'", containerInfo.Status, containerInfo.Pid)
-		return
+	stopSignal := containerInfo.StopSignal
+	if stopSignal == "" {
+		stopSignal = container.DefaultStopSignal
 	}
-	// 将 string 类型的 PID 转换为 int 类型
-	pidInt, err := strconv.Atoi(containerInfo.Pid)
+	sig, err := container.ParseSignal(stopSignal)
 	if err != nil {
-		log.Errorf("Conver pid from string to int error %v", err)
-		return
+		return err
+	}
+	if err := syscall.Kill(pid, sig); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("stop container %s error: %v", containerInfo.Name, err)
+	}
+	if waitContainerStopped(containerInfo.Id, timeout) {
+		return nil
 	}
-	// 系统调用 kill 可以发送信号给迸程 ，通过传递 syscall.SIGTERM 信号，去杀掉容搭主进程
-	if err := syscall.Kill(pidInt, syscall.SIGTERM); err != nil {
-		log.Errorf("Stop container %s error %v", containerName, err)
-		return
+	log.Warnf("Container %s did not exit within %s, killing it", containerInfo.Name, timeout)
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("kill container %s error: %v", containerInfo.Name, err)
 	}
-	// 容器进程已经被 kill， 所以下面需要修改容器状态，PID 可以直为空
-	containerInfo.Status = container.STOP
-	containerInfo.Pid = ""
-	// 将修改后的信息序列化成 json 的字符串
-	_ = writeContainerInfoByName(containerName, containerInfo)
-	_ = cgroups.NewCgroupManager(container.CGroupName).Destroy()
+	// SIGKILL 之后容器很快退出，只需要等待父进程完成清理
+	if !waitContainerStopped(containerInfo.Id, 10*time.Second) {
+		return fmt.Errorf("container %s did not stop", containerInfo.Name)
+	}
+	return nil
+}
+
+// 等待容器的退出被记录，超时返回 false
+func waitContainerStopped(containerId string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		containerInfo, err := readContainerInfo(containerId)
+		if err != nil {
+			return true
+		}
+		if containerInfo = reconcileContainerInfo(containerInfo); !containerInfo.IsActive() && !containerInfo.IsPending() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
+
+// 标记容器被手动停止，返回最新的容器信息
+func markManualStop(containerId string) (*container.Info, error) {
+	return updateContainerInfo(containerId, func(info *container.Info) error {
+		info.ManualStop = true
+		return nil
+	})
 }
@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/yourtion/ydocker/cgroups"
+	"github.com/yourtion/ydocker/container"
+)
+
+/*
+列出容器中的所有进程：
+	1. 通过容器的 cgroup 找到容器中的所有进程，而不只是记录的 init 进程
+	2. 没有指定 ps 参数时从 /proc 读取进程信息，展示宿主机 PID、容器中的 PID、用户、CPU 与命令行
+	3. 指定 ps 参数时执行宿主机的 ps 命令，只保留属于容器的进程
+*/
+func topContainer(containerName string, psArgs []string) error {
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return fmt.Errorf("get container %s info error: %v", containerName, err)
+	}
+	containerInfo = reconcileContainerInfo(containerInfo)
+	if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+		return fmt.Errorf("container %s is not running, status '%s'", containerName, containerInfo.Status)
+	}
+	pids, err := containerPids(containerInfo)
+	if err != nil {
+		return err
+	}
+	if len(psArgs) > 0 {
+		return printPsOutput(pids, psArgs)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 8, 1, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "USER\tPID\tNSPID\tPPID\t%CPU\tTIME\tCMD")
+	for _, pid := range pids {
+		process, err := container.ReadProcess(pid)
+		if err != nil {
+			// 进程可能在读取期间已经退出
+			log.Debugf("Read process %d error %v", pid, err)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.1f\t%s\t%s\n", process.User, process.Pid, process.NsPid,
+			process.PPid, process.CPUPercent, formatCPUTime(process.CPUTime), process.Command)
+	}
+	if err := w.Flush(); err != nil {
+		log.Errorf("Flush error %v", err)
+	}
+	return nil
+}
+
+// 容器 cgroup 中的所有进程，读取 cgroup 失败时只返回容器的 init 进程
+func containerPids(containerInfo *container.Info) ([]int, error) {
+	pids, err := cgroups.NewCgroupManager(containerInfo.CgroupPath).GetPids()
+	if err == nil && len(pids) > 0 {
+		return pids, nil
+	}
+	log.Warnf("Get pids of cgroup %s error %v, only show the init process", containerInfo.CgroupPath, err)
+	pid, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pid '%s' of container %s", containerInfo.Pid, containerInfo.Name)
+	}
+	return []int{pid}, nil
+}
+
+// 执行 ps 命令并只输出容器中的进程，ps 的输出中必须包含 PID 列
+func printPsOutput(pids []int, psArgs []string) error {
+	output, err := exec.Command("ps", psArgs...).Output()
+	if err != nil {
+		return fmt.Errorf("run ps %s error: %v", strings.Join(psArgs, " "), err)
+	}
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	pidIndex := -1
+	for i, field := range strings.Fields(lines[0]) {
+		if field == "PID" {
+			pidIndex = i
+			break
+		}
+	}
+	if pidIndex < 0 {
+		return fmt.Errorf("couldn't find PID field in ps output")
+	}
+	inContainer := map[string]bool{}
+	for _, pid := range pids {
+		inContainer[strconv.Itoa(pid)] = true
+	}
+	fmt.Println(lines[0])
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) > pidIndex && inContainer[fields[pidIndex]] {
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
+
+// 与 ps 的 TIME 列一样使用 [DD-]hh:mm:ss 格式
+func formatCPUTime(d time.Duration) string {
+	seconds := int(d.Seconds())
+	days, seconds := seconds/86400, seconds%86400
+	text := fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+	if days > 0 {
+		return fmt.Sprintf("%d-%s", days, text)
+	}
+	return text
+}
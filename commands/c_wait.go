@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/yourtion/ydocker/container"
+)
+
+// pidfd_open 的系统调用号（Linux 5.3+，各架构相同）
+const sysPidfdOpen = 434
+
+// 等待多个容器退出并依次打印退出码，某个容器出错时继续等待其他容器
+func waitContainers(containerNames []string) error {
+	var failed []string
+	for _, containerName := range containerNames {
+		exitCode, err := waitContainerExit(containerName)
+		if err != nil {
+			log.Errorf("Wait container %s error %v", containerName, err)
+			failed = append(failed, containerName)
+			continue
+		}
+		fmt.Println(exitCode)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to wait containers: %v", failed)
+	}
+	return nil
+}
+
+/*
+阻塞直到容器退出，返回容器的退出码：
+	1. 当前进程不是容器的父进程，无法使用 wait，通过 pidfd 等待记录容器退出的父进程（shim 或前台的 run）结束
+	2. 父进程按照重启策略重启容器时不会退出，因此只在容器最终退出后返回
+	3. 父进程已经退出而容器进程还在运行时，改为等待容器进程
+	4. 内核不支持 pidfd 或没有记录父进程时退化为轮询容器状态
+	5. --rm 的容器退出后被删除，从事件文件中的 die 事件得到退出码
+*/
+func waitContainerExit(containerName string) (int, error) {
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return 0, err
+	}
+	containerId := containerInfo.Id
+	for {
+		configPath := fmt.Sprintf(container.DefaultInfoLocation, containerId) + container.ConfigName
+		if _, err := os.Stat(configPath); os.IsNotExist(err) {
+			// --rm 的容器退出后容器信息已经被删除，从 die 事件中读取退出码
+			return removedContainerExitCode(containerId), nil
+		}
+		containerInfo, err := readContainerInfo(containerId)
+		if err != nil {
+			return 0, err
+		}
+		containerInfo = reconcileContainerInfo(containerInfo)
+		// 包括旧版本记录的 stopped 状态，create 创建的容器需要等待 start 之后退出
+		if containerInfo.IsStopped() && !containerInfo.IsPending() {
+			return containerInfo.ExitCode, nil
+		}
+		pid := containerInfo.MonitorPid
+		if !isProcessAlive(pid) {
+			pid, _ = strconv.Atoi(containerInfo.Pid)
+		}
+		if !(containerInfo.IsActive() || containerInfo.IsPending()) || !waitProcessExit(pid) {
+			time.Sleep(stopPollInterval)
+		}
+	}
+}
+
+// 已经被删除的容器最后一次退出的退出码，没有记录时返回 -1
+func removedContainerExitCode(containerId string) int {
+	event, err := container.LastContainerEvent(containerId, "die")
+	if err != nil || event == nil {
+		log.Warnf("Couldn't find exit code of removed container %s, error %v", containerId, err)
+		return -1
+	}
+	exitCode, err := strconv.Atoi(event.Actor.Attributes["exitCode"])
+	if err != nil {
+		return -1
+	}
+	return exitCode
+}
+
+// 通过 pidfd 等待进程退出，不支持 pidfd 或进程已经不存在时返回 false，由调用者等待后重新读取容器状态
+func waitProcessExit(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	fd, _, errno := syscall.Syscall(sysPidfdOpen, uintptr(pid), 0, 0)
+	if errno != 0 {
+		return false
+	}
+	defer syscall.Close(int(fd))
+	// 进程退出后 pidfd 变为可读
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for {
+		n, err := unix.Poll(fds, -1)
+		if err == syscall.EINTR {
+			continue
+		}
+		return err == nil && n > 0
+	}
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 	"os"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
@@ -14,14 +15,30 @@ import (
 func GetCommandList() []cli.Command {
 	return []cli.Command{
 		initCommand,
+		shimCommand,
 		runCommand,
+		createCommand,
 		commitCommand,
 		listCommand,
+		inspectCommand,
+		topCommand,
 		logCommand,
 		execCommand,
 		stopCommand,
+		killCommand,
+		waitCommand,
+		eventsCommand,
+		startCommand,
+		restartCommand,
 		removeCommand,
+		renameCommand,
 		networkCommand,
+		diffCommand,
+		copyCommand,
+		exportCommand,
+		importCommand,
+		imageCommand,
+		systemCommand,
 	}
 }
 
@@ -30,7 +47,7 @@ var runCommand = cli.Command{
 	Name: "run",
 	Usage: `创建一个包含 namespace 和 cgroups 限制的容器 
 			ydocker run -ti [commands]`,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.BoolFlag{
 			Name:  "ti",
 			Usage: "enable tty",
@@ -39,44 +56,110 @@ var runCommand = cli.Command{
 			Name:  "d",
 			Usage: "detach container",
 		},
-		cli.StringFlag{
-			Name:  "m",
-			Usage: "memory limit",
-		},
-		cli.StringFlag{
-			Name:  "cpushare",
-			Usage: "cpushare limit",
-		},
-		cli.StringFlag{
-			Name:  "cpuset",
-			Usage: "cpuset limit",
-		},
-		// 添加 -v 标签
-		cli.StringFlag{
-			Name:  "v",
-			Usage: "volume",
-		},
-		// 提供 run 后面的 -name 指定容器名字参数
-		cli.StringFlag{
-			Name:  "name",
-			Usage: "container name",
-		},
-		cli.StringSliceFlag{
-			Name:  "e",
-			Usage: "set environment",
-		},
-		cli.StringFlag{
-			Name:  "net",
-			Usage: "container network",
-		},
-		cli.StringSliceFlag{
-			Name:  "p",
-			Usage: "port mapping",
-		},
-	},
+	}, containerFlags...),
 	Action: runAction,
 }
 
+// create 只创建容器，容器的 init 进程等待 start 命令后才执行用户命令
+var createCommand = cli.Command{
+	Name: "create",
+	Usage: `创建一个容器但不运行用户命令，使用 start 启动
+			ydocker create --name demo busybox top`,
+	Flags:  containerFlags,
+	Action: createAction,
+}
+
+// run 与 create 共用的容器配置参数
+var containerFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "m",
+		Usage: "memory limit",
+	},
+	cli.StringFlag{
+		Name:  "cpushare",
+		Usage: "cpushare limit",
+	},
+	cli.StringFlag{
+		Name:  "cpuset",
+		Usage: "cpuset limit",
+	},
+	// 添加 -v 标签
+	cli.StringFlag{
+		Name:  "v",
+		Usage: "volume",
+	},
+	// 提供 run 后面的 -name 指定容器名字参数
+	cli.StringFlag{
+		Name:  "name",
+		Usage: "container name",
+	},
+	cli.StringSliceFlag{
+		Name:  "e",
+		Usage: "set environment",
+	},
+	cli.StringFlag{
+		Name:  "net",
+		Usage: "container network",
+	},
+	cli.StringSliceFlag{
+		Name:  "p",
+		Usage: "port mapping",
+	},
+	cli.StringFlag{
+		Name:  "restart",
+		Value: container.RestartNo,
+		Usage: "restart policy: no, on-failure[:max-retries], always, unless-stopped",
+	},
+	cli.BoolFlag{
+		Name:  "rm",
+		Usage: "automatically remove the container when it exits",
+	},
+	cli.StringSliceFlag{
+		Name:  "label",
+		Usage: "set metadata on the container, key=value",
+	},
+	cli.StringSliceFlag{
+		Name:  "label-file",
+		Usage: "read in a line delimited file of labels",
+	},
+	cli.StringFlag{
+		Name:  "health-cmd",
+		Usage: "command to run to check health",
+	},
+	cli.DurationFlag{
+		Name:  "health-interval",
+		Usage: "time between running the check (default 30s)",
+	},
+	cli.DurationFlag{
+		Name:  "health-timeout",
+		Usage: "maximum time to allow one check to run (default 30s)",
+	},
+	cli.IntFlag{
+		Name:  "health-retries",
+		Usage: "consecutive failures needed to report unhealthy (default 3)",
+	},
+	cli.DurationFlag{
+		Name:  "health-start-period",
+		Usage: "start period for the container to initialize before counting retries towards unstable",
+	},
+	cli.StringSliceFlag{
+		Name:  "ulimit",
+		Usage: "ulimit options, e.g. nofile=65536:65536",
+	},
+	cli.StringSliceFlag{
+		Name:  "sysctl",
+		Usage: "namespaced kernel parameters, e.g. net.core.somaxconn=1024",
+	},
+	cli.StringFlag{
+		Name:  "hostname",
+		Usage: "container host name (default: short container ID)",
+	},
+	cli.StringFlag{
+		Name:  "domainname",
+		Usage: "container NIS domain name",
+	},
+}
+
 /*
 这里是 run 命令执行的真正函数。
 	1. 判断参数是否包含 commands
@@ -84,22 +167,44 @@ var runCommand = cli.Command{
 	3. 调用 Run function 去准备启动容器
 */
 func runAction(ctx *cli.Context) error {
+	tty := ctx.Bool("ti")
+	detach := ctx.Bool("d")
+	if tty && detach {
+		return fmt.Errorf("ti and d paramter can not both provided")
+	}
+	containerInfo, err := containerInfoFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	return run(tty, containerInfo)
+}
+
+func createAction(ctx *cli.Context) error {
+	containerInfo, err := containerInfoFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	return create(containerInfo)
+}
+
+// 根据命令行参数与镜像配置生成容器信息
+func containerInfoFromContext(ctx *cli.Context) (*container.Info, error) {
 	if len(ctx.Args()) < 1 {
-		return fmt.Errorf("缺少 container 参数")
+		return nil, fmt.Errorf("缺少 image 参数")
 	}
 	var cmdArray []string
 	for _, arg := range ctx.Args() {
 		cmdArray = append(cmdArray, arg)
 	}
-	if cmdArray == nil || len(cmdArray) < 2 {
-		return fmt.Errorf("缺少 image 参数")
-	}
 	imageName := cmdArray[0]
-	cmdArray = cmdArray[1:]
-	tty := ctx.Bool("ti")
-	detach := ctx.Bool("d")
-	if tty && detach {
-		return fmt.Errorf("ti and d paramter can not both provided")
+	// 未指定命令时使用镜像配置中的默认命令
+	imageConfig, err := container.LoadImageConfig(imageName)
+	if err != nil {
+		return nil, fmt.Errorf("load image %s config error: %v", imageName, err)
+	}
+	cmdArray = imageConfig.Command(cmdArray[1:])
+	if len(cmdArray) < 1 {
+		return nil, fmt.Errorf("缺少 command 参数")
 	}
 	resConf := &subsystems.ResourceConfig{
 		MemoryLimit: ctx.String("m"),
@@ -110,11 +215,80 @@ func runAction(ctx *cli.Context) error {
 	volume := ctx.String("v")
 	// 将取到的容器名称传递下去，如果没有则取到的值为空
 	containerName := ctx.String("name")
-	envSlice := ctx.StringSlice("e")
-	network := ctx.String("net")
-	portMapping := ctx.StringSlice("p")
-	run(tty, cmdArray, resConf, containerName, volume, imageName, envSlice, network, portMapping)
-	return nil
+	envSlice := append(imageConfig.Env, ctx.StringSlice("e")...)
+	restartPolicy, err := container.ParseRestartPolicy(ctx.String("restart"))
+	if err != nil {
+		return nil, err
+	}
+	labels, err := container.ParseLabels(ctx.StringSlice("label"), ctx.StringSlice("label-file"))
+	if err != nil {
+		return nil, err
+	}
+	healthcheck, err := healthConfigFromContext(ctx, imageConfig.Healthcheck)
+	if err != nil {
+		return nil, err
+	}
+	ulimits, err := container.ParseUlimits(globalConfig.DefaultUlimits, ctx.StringSlice("ulimit"))
+	if err != nil {
+		return nil, err
+	}
+	sysctls, err := container.ParseSysctls(ctx.StringSlice("sysctl"))
+	if err != nil {
+		return nil, err
+	}
+	hostname, domainname := ctx.String("hostname"), ctx.String("domainname")
+	for _, name := range []string{hostname, domainname} {
+		if name == "" {
+			continue
+		}
+		if err := container.ValidateHostname(name); err != nil {
+			return nil, err
+		}
+	}
+	autoRemove := ctx.Bool("rm")
+	if autoRemove && restartPolicy.Name != container.RestartNo {
+		return nil, fmt.Errorf("conflicting options: --restart and --rm")
+	}
+	return &container.Info{
+		Name:          containerName,
+		Args:          cmdArray,
+		Env:           envSlice,
+		Resource:      resConf,
+		Volume:        volume,
+		Image:         imageName,
+		Network:       ctx.String("net"),
+		PortMapping:   ctx.StringSlice("p"),
+		RestartPolicy: restartPolicy,
+		StopSignal:    imageConfig.StopSignal,
+		AutoRemove:    autoRemove,
+		Labels:        labels,
+		Healthcheck:   healthcheck,
+		Ulimits:       ulimits,
+		Sysctls:       sysctls,
+		Hostname:      hostname,
+		Domainname:    domainname,
+	}, nil
+}
+
+// 使用命令行参数覆盖镜像中的健康检查配置，没有启用健康检查时返回 nil
+func healthConfigFromContext(ctx *cli.Context, imageHealthcheck *container.HealthConfig) (*container.HealthConfig, error) {
+	override := &container.HealthConfig{
+		Interval:    ctx.Duration("health-interval"),
+		Timeout:     ctx.Duration("health-timeout"),
+		StartPeriod: ctx.Duration("health-start-period"),
+		Retries:     ctx.Int("health-retries"),
+	}
+	if override.Interval < 0 || override.Timeout < 0 || override.StartPeriod < 0 || override.Retries < 0 {
+		return nil, fmt.Errorf("health check options can not be negative")
+	}
+	if cmd := ctx.String("health-cmd"); cmd != "" {
+		override.Test = []string{"CMD-SHELL", cmd}
+	}
+	healthcheck := imageHealthcheck.Merge(override)
+	if !healthcheck.Enabled() {
+		return nil, nil
+	}
+	return healthcheck, nil
 }
 
 // 这里，定义了 initCommand 的具体操作，此操作为内部方法，禁止外部调用
@@ -130,6 +304,25 @@ var initCommand = cli.Command{
 	},
 }
 
+// 后台容器的监控进程，负责等待容器退出并清理资源，此操作为内部方法，禁止外部调用
+var shimCommand = cli.Command{
+	Name:   "shim",
+	Usage:  `监控容器进程并在退出后清理资源（禁止外部调用）`,
+	Hidden: true,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "wait-start",
+			Usage: "wait for start command before running user command",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		return shimContainer(ctx.Args().Get(0), ctx.Bool("wait-start"))
+	},
+}
+
 var commitCommand = cli.Command{
 	Name:  "commit",
 	Usage: "commit a container into image",
@@ -146,10 +339,37 @@ var commitCommand = cli.Command{
 
 var listCommand = cli.Command{
 	Name:  "ps",
-	Usage: "list all the containers",
+	Usage: "list containers",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "all, a",
+			Usage: "show all containers (default shows just running)",
+		},
+		cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: "filter output based on conditions: id, name, status, network, label, health",
+		},
+		cli.BoolFlag{
+			Name:  "quiet, q",
+			Usage: "only display container IDs",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "format the output using a Go template, or json",
+		},
+		cli.BoolFlag{
+			Name:  "size, s",
+			Usage: "display size of the container write layer",
+		},
+	},
 	Action: func(context *cli.Context) error {
-		listContainers()
-		return nil
+		return listContainers(psOptions{
+			All:     context.Bool("all"),
+			Quiet:   context.Bool("quiet"),
+			Size:    context.Bool("size"),
+			Format:  context.String("format"),
+			Filters: context.StringSlice("filter"),
+		})
 	},
 }
 
@@ -191,29 +411,313 @@ var execCommand = cli.Command{
 	},
 }
 
+var topCommand = cli.Command{
+	Name:      "top",
+	Usage:     "display the running processes of a container",
+	ArgsUsage: "CONTAINER [ps OPTIONS]",
+	// ps 的参数原样传递，不作为 top 的参数解析
+	SkipFlagParsing: true,
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		return topContainer(context.Args().Get(0), context.Args().Tail())
+	},
+}
+
 var stopCommand = cli.Command{
 	Name:  "stop",
-	Usage: "stop a container",
+	Usage: "stop one or more containers",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "t",
+			Value: 10,
+			Usage: "seconds to wait for stop before killing the container",
+		},
+		cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: "stop running containers matching the conditions, e.g. label=team=infra",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		timeout := time.Duration(context.Int("t")) * time.Second
+		return stopContainers(context.Args(), context.StringSlice("filter"), timeout)
+	},
+}
+
+var killCommand = cli.Command{
+	Name:  "kill",
+	Usage: "send a signal to a container",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "s",
+			Value: "SIGKILL",
+			Usage: "signal to send to the container",
+		},
+	},
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("missing container name")
 		}
-		containerName := context.Args().Get(0)
-		stopContainer(containerName)
-		return nil
+		return killContainer(context.Args().Get(0), context.String("s"))
+	},
+}
+
+var waitCommand = cli.Command{
+	Name:  "wait",
+	Usage: "block until one or more containers stop, then print their exit codes",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		return waitContainers(context.Args())
+	},
+}
+
+var eventsCommand = cli.Command{
+	Name:  "events",
+	Usage: "get real time events as JSON lines",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "since",
+			Usage: "show events created since timestamp, e.g. 1577934245, 2020-01-02T03:04:05Z or 10m",
+		},
+		cli.StringFlag{
+			Name:  "until",
+			Usage: "stream events until this timestamp",
+		},
+		cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: "filter output based on conditions: container, network, event, type, label",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		return streamEvents(context.String("since"), context.String("until"), context.StringSlice("filter"))
+	},
+}
+
+var startCommand = cli.Command{
+	Name:  "start",
+	Usage: "start a stopped container",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "a",
+			Usage: "attach container's output",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		return startStoppedContainer(context.Args().Get(0), context.Bool("a"))
+	},
+}
+
+var restartCommand = cli.Command{
+	Name:  "restart",
+	Usage: "restart a container",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "t",
+			Value: 10,
+			Usage: "seconds to wait for stop before killing the container",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		timeout := time.Duration(context.Int("t")) * time.Second
+		return restartContainer(context.Args().Get(0), timeout)
+	},
+}
+
+var inspectCommand = cli.Command{
+	Name:  "inspect",
+	Usage: "display detailed information on containers, images, networks or volumes",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "type",
+			Usage: "only inspect objects of the given type: container, image, network or volume",
+		},
+		cli.StringFlag{
+			Name:  "format, f",
+			Usage: "format the output using the given Go template",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing object name")
+		}
+		return inspectObjects(context.Args(), context.String("type"), context.String("format"))
+	},
+}
+
+var renameCommand = cli.Command{
+	Name:  "rename",
+	Usage: "rename a container",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 2 {
+			return fmt.Errorf("missing old name or new name")
+		}
+		return renameContainer(context.Args().Get(0), context.Args().Get(1))
 	},
 }
 
 var removeCommand = cli.Command{
 	Name:  "rm",
 	Usage: "remove unused containers",
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: "remove stopped containers matching the conditions, e.g. label=job=build",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		return removeContainers(context.Args(), context.StringSlice("filter"))
+	},
+}
+
+var diffCommand = cli.Command{
+	Name:  "diff",
+	Usage: "inspect changes to files on a container's filesystem",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "output changes as json",
+		},
+	},
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("missing container name")
 		}
-		containerName := context.Args().Get(0)
-		removeContainer(containerName)
-		return nil
+		return diffContainer(context.Args().Get(0), context.Bool("json"))
+	},
+}
+
+var copyCommand = cli.Command{
+	Name: "cp",
+	Usage: `copy files/folders between a container and the local filesystem
+			ydocker cp [-L] CONTAINER:SRC_PATH DEST_PATH|-
+			ydocker cp [-L] SRC_PATH|- CONTAINER:DEST_PATH`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "L",
+			Usage: "always follow symbol link in SRC_PATH",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 2 {
+			return fmt.Errorf("missing source or destination path")
+		}
+		return copyContainer(context.Args().Get(0), context.Args().Get(1), context.Bool("L"))
+	},
+}
+
+var exportCommand = cli.Command{
+	Name:  "export",
+	Usage: "export a container's filesystem as a tar archive",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "o",
+			Usage: "write to a file, instead of STDOUT",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		return exportContainer(context.Args().Get(0), context.String("o"))
+	},
+}
+
+var importCommand = cli.Command{
+	Name: "import",
+	Usage: `import the contents from a tarball to create a filesystem image
+			ydocker import [--change 'CMD ...'] file|- name[:tag]`,
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "change, c",
+			Usage: "apply CMD/ENTRYPOINT/ENV instruction to the created image",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 2 {
+			return fmt.Errorf("missing tar file and image name")
+		}
+		return importImage(context.Args().Get(0), context.Args().Get(1), context.StringSlice("change"))
+	},
+}
+
+var pruneFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "all, a",
+		Usage: "remove all unused images, not just unpacked image layers",
+	},
+	cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "only show what would be removed",
+	},
+}
+
+var imageCommand = cli.Command{
+	Name:  "image",
+	Usage: "manage images",
+	Subcommands: []cli.Command{
+		{
+			Name:  "prune",
+			Usage: "remove image layers and images not used by any container",
+			Flags: pruneFlags,
+			Action: func(context *cli.Context) error {
+				containers, err := getAllContainerInfos()
+				if err != nil {
+					return err
+				}
+				report := newPruneReport(context.Bool("dry-run"))
+				if err := pruneImages(report, containers, context.Bool("all")); err != nil {
+					return err
+				}
+				report.print()
+				return nil
+			},
+		},
+	},
+}
+
+var systemCommand = cli.Command{
+	Name:  "system",
+	Usage: "manage ydocker",
+	Subcommands: []cli.Command{
+		{
+			Name: "prune",
+			Usage: `remove stopped containers, stale container layers, unused networks and images
+			volumes are bind mounted host directories and are never removed`,
+			Flags: append([]cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "filter",
+					Usage: "only remove stopped containers matching the conditions, e.g. label=job=build",
+				},
+			}, pruneFlags...),
+			Action: func(context *cli.Context) error {
+				filter, err := container.ParseFilters(context.StringSlice("filter"))
+				if err != nil {
+					return err
+				}
+				report := newPruneReport(context.Bool("dry-run"))
+				if err := pruneSystem(report, context.Bool("all"), filter); err != nil {
+					return err
+				}
+				report.print()
+				return nil
+			},
+		},
+		{
+			Name:  "df",
+			Usage: "show ydocker disk usage",
+			Action: func(context *cli.Context) error {
+				return systemDiskUsage()
+			},
+		},
 	},
 }
 
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/urfave/cli"
+
+	"github.com/yourtion/ydocker/config"
+	"github.com/yourtion/ydocker/container"
+	"github.com/yourtion/ydocker/network"
+)
+
+// 当前使用的全局配置
+var globalConfig = config.Default()
+
+// 当前使用的配置文件
+var globalConfigPath = config.DefaultConfigPath
+
+// 全局参数，优先级高于配置文件
+var GlobalFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "config",
+		Usage: "config file path",
+		Value: config.DefaultConfigPath,
+	},
+	cli.StringFlag{
+		Name:  "root",
+		Usage: "root directory of images and container layers (default: " + config.DefaultRoot + ")",
+	},
+	cli.StringFlag{
+		Name:  "state-dir",
+		Usage: "directory of container and network state (default: " + config.DefaultStateDir + ")",
+	},
+}
+
+// 加载配置文件与全局参数，并设置各模块使用的目录
+func Setup(ctx *cli.Context) error {
+	conf, err := config.Load(ctx.GlobalString("config"))
+	if err != nil {
+		return fmt.Errorf("load config error: %v", err)
+	}
+	if root := ctx.GlobalString("root"); root != "" {
+		conf.Root = root
+	}
+	if stateDir := ctx.GlobalString("state-dir"); stateDir != "" {
+		conf.StateDir = stateDir
+	}
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	globalConfigPath = ctx.GlobalString("config")
+	container.SetRootUrl(conf.Root)
+	container.SetStateDir(conf.StateDir)
+	network.SetStateDir(conf.StateDir)
+	globalConfig = conf
+	return nil
+}
+
+// 创建重新执行 ydocker 自身的命令，并传递当前的全局配置
+func selfCommand(args ...string) *exec.Cmd {
+	globalArgs := []string{
+		"--config", globalConfigPath,
+		"--root", globalConfig.Root,
+		"--state-dir", globalConfig.StateDir,
+	}
+	return exec.Command("/proc/self/exe", append(globalArgs, args...)...)
+}
@@ -1,11 +1,14 @@
 package commands
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"math/rand"
 	"os"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -13,20 +16,31 @@ import (
 	"github.com/yourtion/ydocker/container"
 )
 
-// 生成随机字符串
-func randStringBytes(n int) string {
-	letterBytes := "1234567890"
-	rand.Seed(time.Now().UnixNano())
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = letterBytes[rand.Intn(len(letterBytes))]
+// 根据容器的完整 ID、容器名或唯一的 ID 前缀获取容器信息
+func getContainerInfoByName(containerName string) (*container.Info, error) {
+	// 容器信息目录以容器 ID 命名，完整 ID 可以直接读取
+	if _, err := os.Stat(fmt.Sprintf(container.DefaultInfoLocation, containerName) + container.ConfigName); err == nil {
+		containerInfo, err := readContainerInfo(containerName)
+		if err != nil {
+			return nil, err
+		}
+		// 旧版本的容器目录以容器名命名
+		if containerInfo.Id != containerName {
+			migrateContainerDir(containerName, containerInfo)
+		}
+		return containerInfo, nil
 	}
-	return string(b)
+	containers, err := getAllContainerInfos()
+	if err != nil {
+		return nil, err
+	}
+	return container.FindContainer(containers, containerName)
 }
 
-func getContainerInfoByName(containerName string) (*container.Info, error) {
+// 读取容器 ID 对应目录下的 config.json
+func readContainerInfo(containerId string) (*container.Info, error) {
 	// 根据文件名生成文件绝对路径
-	configFileDir := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+	configFileDir := fmt.Sprintf(container.DefaultInfoLocation, containerId)
 	configFileDir = configFileDir + container.ConfigName
 	// 读取 config.json 文件内的容器信息
 	content, err := ioutil.ReadFile(configFileDir)
@@ -44,6 +58,79 @@ func getContainerInfoByName(containerName string) (*container.Info, error) {
 	return &containerInfo, nil
 }
 
+// 获取所有容器的信息
+func getAllContainerInfos() ([]*container.Info, error) {
+	// 找到存储容器信息的路径 /var/run/ydocker
+	dirURL := fmt.Sprintf(container.DefaultInfoLocation, "")
+	dirURL = dirURL[:len(dirURL)-1]
+	// 读取该文件夹下的所有文件
+	files, err := ioutil.ReadDir(dirURL)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// 遍历该文件夹下的所有文件
+	var containers []*container.Info
+	for _, file := range files {
+		// 跳过 network 等不是容器的目录与事件文件
+		if !file.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(dirURL + "/" + file.Name() + "/" + container.ConfigName); os.IsNotExist(err) {
+			continue
+		}
+		// 根据容器配置文件获取对应的信息，然后转换成容器信息的对象
+		tmpContainer, err := readContainerInfo(file.Name())
+		if err != nil {
+			log.Errorf("Get container info error %v", err)
+			continue
+		}
+		if tmpContainer.Id != file.Name() {
+			migrateContainerDir(file.Name(), tmpContainer)
+		}
+		containers = append(containers, tmpContainer)
+	}
+	return containers, nil
+}
+
+/*
+迁移旧版本以容器名命名的容器：
+	1. 对旧目录加锁后确认容器还没有被其他进程迁移，再把容器信息目录重命名为容器 ID
+	2. 可写层与挂载点同样迁移到容器 ID 对应的目录，之后所有操作都按容器 ID 访问
+*/
+func migrateContainerDir(dirName string, containerInfo *container.Info) {
+	if containerInfo.Id == "" {
+		return
+	}
+	oldURL := fmt.Sprintf(container.DefaultInfoLocation, dirName)
+	lock, err := os.Open(oldURL)
+	if err != nil {
+		return
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		log.Errorf("Lock %s error %v", oldURL, err)
+		return
+	}
+	if _, err := os.Stat(oldURL + container.ConfigName); err != nil {
+		return
+	}
+	newURL := fmt.Sprintf(container.DefaultInfoLocation, containerInfo.Id)
+	if _, err := os.Stat(newURL); err == nil {
+		log.Errorf("Migrate container %s error: %s already exists", dirName, newURL)
+		return
+	}
+	if err := os.Rename(oldURL, newURL); err != nil {
+		log.Errorf("Migrate container %s error %v", dirName, err)
+		return
+	}
+	if err := container.MigrateWorkSpace(dirName, containerInfo.Id); err != nil {
+		log.Errorf("Migrate container %s filesystem error %v", dirName, err)
+	}
+}
+
 // 根据提供的容器名获取对应容器的 PIO
 func getContainerPidByName(containerName string) (string, error) {
 	containerInfo, err := getContainerInfoByName(containerName)
@@ -53,22 +140,118 @@ func getContainerPidByName(containerName string) (string, error) {
 	return containerInfo.Pid, nil
 }
 
-func writeContainerInfoByName(containerName string, containerInfo *container.Info) error {
+// 保存容器信息到容器 ID 对应目录下的 config.json
+func writeContainerInfo(containerInfo *container.Info) error {
 	newContentBytes, err := json.Marshal(containerInfo)
 	if err != nil {
-		log.Errorf("Json marshal %s error %v", containerName, err)
+		log.Errorf("Json marshal %s error %v", containerInfo.Name, err)
 		return err
 	}
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerInfo.Id)
 	configFilePath := dirURL + container.ConfigName
-	// 重新写入新的数据覆盖原来的信息
-	if err := ioutil.WriteFile(configFilePath, newContentBytes, 0622); err != nil {
-		log.Errorf("Write file %s error %v", configFilePath, err)
+	// 先写入临时文件再重命名覆盖原来的信息，避免其他进程读到写了一半的文件
+	tmpFilePath := configFilePath + ".tmp"
+	if err := ioutil.WriteFile(tmpFilePath, newContentBytes, 0622); err != nil {
+		log.Errorf("Write file %s error %v", tmpFilePath, err)
+		return err
+	}
+	if err := os.Rename(tmpFilePath, configFilePath); err != nil {
+		log.Errorf("Rename file %s error %v", configFilePath, err)
 		return err
 	}
 	return nil
 }
 
+// 对容器目录加文件锁后读取、修改并保存容器信息，避免 shim、ps、stop 等多个进程同时修改
+func updateContainerInfo(containerId string, update func(*container.Info) error) (*container.Info, error) {
+	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerId)
+	lock, err := os.Open(dirURL)
+	if err != nil {
+		return nil, err
+	}
+	// 关闭文件时自动释放锁
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return nil, fmt.Errorf("lock %s error: %v", dirURL, err)
+	}
+	containerInfo, err := readContainerInfo(containerId)
+	if err != nil {
+		return nil, err
+	}
+	if err := update(containerInfo); err != nil {
+		return nil, err
+	}
+	if err := writeContainerInfo(containerInfo); err != nil {
+		return nil, err
+	}
+	return containerInfo, nil
+}
+
+// 对容器信息的根目录加文件锁，保证检查容器名与保存容器名之间不会有其他进程使用同一个容器名，返回释放锁的函数
+func lockStateDir() (func(), error) {
+	dirURL := fmt.Sprintf(container.DefaultInfoLocation, "")
+	if err := os.MkdirAll(dirURL, 0622); err != nil {
+		return nil, err
+	}
+	lock, err := os.Open(dirURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		_ = lock.Close()
+		return nil, fmt.Errorf("lock %s error: %v", dirURL, err)
+	}
+	return func() { _ = lock.Close() }, nil
+}
+
+// 判断进程是否存在，没有被回收的僵尸进程视为已经退出
+func isProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	if err := syscall.Kill(pid, 0); err != nil && err != syscall.EPERM {
+		return false
+	}
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+	// /proc/<pid>/stat 的第三列为进程状态，进程名可能包含空格，从最后一个 ")" 之后解析
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
+
+/*
+校正容器状态：容器进程与等待它的父进程都已经不存在时，说明容器的退出没有被记录（例如 shim 被杀死），
+此时将容器标记为 dead，其占用的资源在删除容器时清理
+*/
+func reconcileContainerInfo(containerInfo *container.Info) *container.Info {
+	if !containerInfo.IsActive() && !containerInfo.IsPending() {
+		return containerInfo
+	}
+	pid, _ := strconv.Atoi(containerInfo.Pid)
+	if isProcessAlive(pid) || isProcessAlive(containerInfo.MonitorPid) {
+		return containerInfo
+	}
+	latest, err := updateContainerInfo(containerInfo.Id, func(info *container.Info) error {
+		if !info.IsActive() && !info.IsPending() {
+			return nil
+		}
+		if err := info.SetStatus(container.DEAD); err != nil {
+			return err
+		}
+		info.Pid = ""
+		info.ExitCode = -1
+		info.FinishedTime = time.Now().Format(container.TimeFormat)
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Reconcile container %s status error %v", containerInfo.Name, err)
+		return containerInfo
+	}
+	return latest
+}
+
 // 删除容器信息
 func deleteContainerInfo(containerId string) {
 	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerId)
@@ -76,3 +259,58 @@ func deleteContainerInfo(containerId string) {
 		log.Errorf("Remove dir %s error %v", dirURL, err)
 	}
 }
+
+// 将字节数转换为易读的大小
+func humanSize(size int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1000 && i < len(units)-1 {
+		value /= 1000
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d%s", size, units[i])
+	}
+	return fmt.Sprintf("%.3g%s", value, units[i])
+}
+
+/*
+根据容器名与过滤条件选择要操作的容器，返回容器 ID：
+	1. 只指定过滤条件时，从所有容器中选择符合条件且 accept 返回 true 的容器
+	2. 同时指定容器名时，只在指定的容器中按过滤条件选择
+*/
+func selectContainers(names, filters []string, accept func(*container.Info) bool) ([]string, error) {
+	if len(names) == 0 && len(filters) == 0 {
+		return nil, fmt.Errorf("missing container name")
+	}
+	filter, err := container.ParseFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > 0 {
+		var ids []string
+		for _, name := range names {
+			containerInfo, err := getContainerInfoByName(name)
+			if err != nil {
+				return nil, fmt.Errorf("get container %s info error: %v", name, err)
+			}
+			if filter.Match(containerInfo) {
+				ids = append(ids, containerInfo.Id)
+			}
+		}
+		return ids, nil
+	}
+	containers, err := getAllContainerInfos()
+	if err != nil {
+		return nil, fmt.Errorf("get containers error: %v", err)
+	}
+	var ids []string
+	for _, item := range containers {
+		item = reconcileContainerInfo(item)
+		if filter.Match(item) && accept(item) {
+			ids = append(ids, item.Id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/yourtion/ydocker/container"
+)
+
+const (
+	// 默认配置文件路径
+	DefaultConfigPath = "/etc/ydocker/config.json"
+	// 默认的镜像与容器文件系统存放目录
+	DefaultRoot = "/root"
+	// 默认的容器与网络运行状态存放目录
+	DefaultStateDir = "/var/run/ydocker"
+)
+
+// ydocker 的全局配置
+type Config struct {
+	Root           string   `json:"root"`                     // 镜像、容器只读层与可写层的存放目录
+	StateDir       string   `json:"stateDir"`                 // 容器信息、网络与 IPAM 的存放目录
+	DefaultUlimits []string `json:"defaultUlimits,omitempty"` // 容器默认的资源限制，格式与 --ulimit 相同
+}
+
+// 默认配置
+func Default() *Config {
+	return &Config{
+		Root:     DefaultRoot,
+		StateDir: DefaultStateDir,
+	}
+}
+
+// 读取配置文件，文件不存在时返回默认配置，配置文件中未设置的项使用默认值
+func Load(path string) (*Config, error) {
+	conf := Default()
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return conf, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(content, conf); err != nil {
+		return nil, fmt.Errorf("parse config %s error: %v", path, err)
+	}
+	if conf.Root == "" {
+		conf.Root = DefaultRoot
+	}
+	if conf.StateDir == "" {
+		conf.StateDir = DefaultStateDir
+	}
+	return conf, nil
+}
+
+// 检查配置并将目录转换为绝对路径，默认资源限制有误时在启动时报错而不是等到创建容器
+func (c *Config) Validate() error {
+	var err error
+	if c.Root, err = filepath.Abs(c.Root); err != nil {
+		return fmt.Errorf("invalid root %s: %v", c.Root, err)
+	}
+	if c.StateDir, err = filepath.Abs(c.StateDir); err != nil {
+		return fmt.Errorf("invalid state dir %s: %v", c.StateDir, err)
+	}
+	for _, value := range c.DefaultUlimits {
+		if _, err := container.ParseUlimit(value); err != nil {
+			return fmt.Errorf("invalid default ulimits: %v", err)
+		}
+	}
+	return nil
+}
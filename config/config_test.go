@@ -0,0 +1,51 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	conf, err := Load(filepath.Join(dir, "missing.json"))
+	if err != nil || conf.Root != DefaultRoot || conf.StateDir != DefaultStateDir {
+		t.Fatalf("load missing config %+v error %v", conf, err)
+	}
+
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte(`{"root": "/data/ydocker"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf, err = Load(path)
+	if err != nil || conf.Root != "/data/ydocker" || conf.StateDir != DefaultStateDir {
+		t.Fatalf("load config %+v error %v", conf, err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"defaultUlimits": ["nofile=65536:65536"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf, err = Load(path)
+	if err != nil || len(conf.DefaultUlimits) != 1 || conf.DefaultUlimits[0] != "nofile=65536:65536" {
+		t.Fatalf("load config %+v error %v", conf, err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"root": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("load invalid config should fail")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	conf := &Config{Root: "data", StateDir: "/run/ydocker", DefaultUlimits: []string{"nofile=1024:4096"}}
+	if err := conf.Validate(); err != nil || !filepath.IsAbs(conf.Root) {
+		t.Fatalf("validate config %+v error %v", conf, err)
+	}
+
+	conf.DefaultUlimits = []string{"nofile=4096:1024"}
+	if err := conf.Validate(); err == nil {
+		t.Fatal("validate config with invalid default ulimits should fail")
+	}
+}
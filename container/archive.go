@@ -0,0 +1,323 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// 解析符号链接时允许的最大跳转次数
+const maxSymlinkDepth = 255
+
+/*
+将 unsafePath 限定在 root 下解析，等价于把 root 当作 "/" 来跟随路径中的符号链接，
+绝对路径的链接与 ".." 都不会逃逸出 root，用于安全地访问容器文件系统中的路径。
+*/
+func SecureJoin(root, unsafePath string) (string, error) {
+	var path bytes.Buffer
+	n := 0
+	for unsafePath != "" {
+		if n > maxSymlinkDepth {
+			return "", &os.PathError{Op: "SecureJoin", Path: root + "/" + unsafePath, Err: syscall.ELOOP}
+		}
+		// 取出下一级路径
+		var p string
+		if i := strings.IndexRune(unsafePath, '/'); i == -1 {
+			p, unsafePath = unsafePath, ""
+		} else {
+			p, unsafePath = unsafePath[:i], unsafePath[i+1:]
+		}
+		// 按 "/" 为根做词法清理，保证 ".." 不会越过 root
+		cleanP := filepath.Clean("/" + path.String() + p)
+		if cleanP == "/" {
+			path.Reset()
+			continue
+		}
+		fullP := filepath.Clean(root + cleanP)
+		fi, err := os.Lstat(fullP)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		// 不存在的路径与普通文件一样直接拼接
+		if os.IsNotExist(err) || fi.Mode()&os.ModeSymlink == 0 {
+			path.WriteString(p)
+			path.WriteRune('/')
+			continue
+		}
+		// 符号链接则将链接内容放回待解析的路径前面，绝对路径的链接从 root 重新开始
+		n++
+		dest, err := os.Readlink(fullP)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(dest) {
+			path.Reset()
+		}
+		unsafePath = dest + "/" + unsafePath
+	}
+	return filepath.Clean(root + filepath.Clean("/"+path.String())), nil
+}
+
+// 与 SecureJoin 相同，但不跟随最后一级的符号链接
+func secureJoinNoFollow(root, unsafePath string) (string, error) {
+	dir, base := filepath.Split(filepath.Clean("/" + unsafePath))
+	if base == "" {
+		return root, nil
+	}
+	parent, err := SecureJoin(root, dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(parent, base), nil
+}
+
+// 将宿主机上的相对路径转换为基于当前目录的绝对路径，保留表示目录的结尾 "/" 与 "/."
+func HostPath(path string) (string, error) {
+	if path == "-" || filepath.IsAbs(path) {
+		return path, nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case path == "." || strings.HasSuffix(path, "/."):
+		abs += "/."
+	case strings.HasSuffix(path, "/"):
+		abs += "/"
+	}
+	return abs, nil
+}
+
+// 打包选项
+type ArchiveOptions struct {
+	// 包内条目的顶层名字，为空时只打包目录下的内容，对应 docker cp 的 "src/." 写法
+	Name string
+	// 跟随 srcPath 本身的符号链接，目录内的符号链接始终原样保留
+	FollowLink bool
+	// 不进入挂载在其他文件系统上的目录，例如容器中挂载的数据卷
+	OneFileSystem bool
+}
+
+// 将 root 下的 srcPath 打包成 tar 流写入 w
+func ArchivePath(w io.Writer, root, srcPath string, opts ArchiveOptions) error {
+	var src string
+	var err error
+	if opts.FollowLink {
+		src, err = SecureJoin(root, srcPath)
+	} else {
+		src, err = secureJoinNoFollow(root, srcPath)
+	}
+	if err != nil {
+		return err
+	}
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(w)
+	err = filepath.Walk(src, func(p string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		entryName := filepath.Join(opts.Name, rel)
+		if entryName == "." {
+			return nil
+		}
+		link := ""
+		if f.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(f, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = entryName
+		if f.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if f.IsDir() && opts.OneFileSystem && !sameDevice(srcInfo, f) {
+			return filepath.SkipDir
+		}
+		if !f.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func sameDevice(a, b os.FileInfo) bool {
+	statA, okA := a.Sys().(*syscall.Stat_t)
+	statB, okB := b.Sys().(*syscall.Stat_t)
+	return !okA || !okB || statA.Dev == statB.Dev
+}
+
+// 将 tar 流解压到 root 下的 dstDir 目录，每个条目都在 root 内解析，防止通过符号链接写到 root 之外
+func ExtractArchive(r io.Reader, root, dstDir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, err := secureJoinNoFollow(root, filepath.Join(dstDir, hdr.Name))
+		if err != nil {
+			return err
+		}
+		if err := extractEntry(tr, hdr, root, dstDir, target); err != nil {
+			return fmt.Errorf("extract %s error: %v", hdr.Name, err)
+		}
+	}
+}
+
+func extractEntry(tr *tar.Reader, hdr *tar.Header, root, dstDir, target string) error {
+	mode := os.FileMode(hdr.Mode).Perm()
+	// 目标位置已存在的非目录文件直接替换
+	if fi, err := os.Lstat(target); err == nil && !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(target, mode); err != nil {
+			return err
+		}
+	case tar.TypeReg, tar.TypeRegA:
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(file, tr)
+		_ = file.Close()
+		if err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		return os.Symlink(hdr.Linkname, target)
+	case tar.TypeLink:
+		source, err := secureJoinNoFollow(root, filepath.Join(dstDir, hdr.Linkname))
+		if err != nil {
+			return err
+		}
+		return os.Link(source, target)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		devMode := uint32(syscall.S_IFCHR)
+		if hdr.Typeflag == tar.TypeBlock {
+			devMode = syscall.S_IFBLK
+		} else if hdr.Typeflag == tar.TypeFifo {
+			devMode = syscall.S_IFIFO
+		}
+		dev := int((hdr.Devminor & 0xff) | ((hdr.Devmajor & 0xfff) << 8) | ((hdr.Devminor &^ 0xff) << 12))
+		if err := syscall.Mknod(target, devMode|uint32(mode), dev); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	_ = os.Lchown(target, hdr.Uid, hdr.Gid)
+	if err := os.Chmod(target, mode); err != nil {
+		return err
+	}
+	return os.Chtimes(target, hdr.AccessTime, hdr.ModTime)
+}
+
+/*
+在 srcRoot 与 dstRoot 两个根目录之间复制文件，语义与 docker cp 一致：
+	1. dstPath 是已存在的目录时，将 srcPath 复制到该目录下
+	2. dstPath 不存在时，将 srcPath 复制为 dstPath，此时 dstPath 的父目录必须存在
+	3. srcPath 以 "/." 结尾时，只复制目录中的内容
+*/
+func CopyPath(srcRoot, srcPath, dstRoot, dstPath string, followLink bool) error {
+	var src string
+	var err error
+	if followLink {
+		src, err = SecureJoin(srcRoot, srcPath)
+	} else {
+		src, err = secureJoinNoFollow(srcRoot, srcPath)
+	}
+	if err != nil {
+		return err
+	}
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	contentOnly := strings.HasSuffix(srcPath, "/.")
+	if contentOnly && !srcInfo.IsDir() {
+		return fmt.Errorf("source %s is not a directory", srcPath)
+	}
+	name := filepath.Base(filepath.Clean("/" + srcPath))
+	if contentOnly {
+		name = ""
+	}
+
+	dstDir := dstPath
+	dst, err := SecureJoin(dstRoot, dstPath)
+	if err != nil {
+		return err
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err != nil || !dstInfo.IsDir() {
+		if err != nil && strings.HasSuffix(dstPath, "/") {
+			return fmt.Errorf("destination directory %s does not exist", dstPath)
+		}
+		if err == nil && srcInfo.IsDir() {
+			return fmt.Errorf("cannot copy a directory to file %s", dstPath)
+		}
+		// 目标不存在时，以目标路径的文件名作为复制后的名字
+		dstDir, name = filepath.Split(filepath.Clean("/" + dstPath))
+		parent, err := SecureJoin(dstRoot, dstDir)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(parent); err != nil {
+			return err
+		}
+		if contentOnly {
+			if err := os.Mkdir(dst, srcInfo.Mode().Perm()); err != nil {
+				return err
+			}
+			dstDir, name = dstPath, ""
+		}
+	}
+
+	// 通过管道将打包和解压连接起来，以流的方式复制
+	reader, writer := io.Pipe()
+	go func() {
+		_ = writer.CloseWithError(ArchivePath(writer, srcRoot, srcPath, ArchiveOptions{Name: name, FollowLink: followLink}))
+	}()
+	err = ExtractArchive(reader, dstRoot, dstDir)
+	_ = reader.CloseWithError(err)
+	return err
+}
@@ -0,0 +1,133 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecureJoin(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "etc", "passwd"))
+	if err := os.Symlink("/etc", filepath.Join(root, "abs")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../../..", filepath.Join(root, "etc", "up")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"etc/passwd":     "/etc/passwd",
+		"../../etc":      "/etc",
+		"abs/passwd":     "/etc/passwd",
+		"etc/up/tmp":     "/tmp",
+		"etc/up/abs/new": "/etc/new",
+	}
+	for unsafePath, expect := range cases {
+		path, err := SecureJoin(root, unsafePath)
+		if err != nil {
+			t.Fatalf("SecureJoin %s error %v", unsafePath, err)
+		}
+		if path != filepath.Join(root, expect) {
+			t.Fatalf("SecureJoin %s got %s, expect %s", unsafePath, path, filepath.Join(root, expect))
+		}
+	}
+}
+
+func TestCopyPath(t *testing.T) {
+	srcRoot := t.TempDir()
+	dstRoot := t.TempDir()
+	writeTestFile(t, filepath.Join(srcRoot, "app", "conf", "a.conf"))
+	if err := os.Symlink("/etc", filepath.Join(dstRoot, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 复制目录到已存在的目录下
+	if err := CopyPath(srcRoot, "/app", dstRoot, "/", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dstRoot, "app", "conf", "a.conf")); err != nil {
+		t.Fatal(err)
+	}
+	// 复制文件并重命名
+	if err := CopyPath(srcRoot, "/app/conf/a.conf", dstRoot, "/b.conf", false); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(dstRoot, "b.conf")); err != nil || string(content) != "test" {
+		t.Fatalf("read b.conf %s error %v", content, err)
+	}
+	// 只复制目录内容到新目录
+	if err := CopyPath(srcRoot, "/app/.", dstRoot, "/app2", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dstRoot, "app2", "conf", "a.conf")); err != nil {
+		t.Fatal(err)
+	}
+	// 通过符号链接写入的文件不能逃逸出根目录
+	if err := CopyPath(srcRoot, "/app/conf/a.conf", dstRoot, "/escape/", false); err == nil {
+		t.Fatal("copy to missing directory should fail")
+	}
+	if err := os.MkdirAll(filepath.Join(dstRoot, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyPath(srcRoot, "/app/conf/a.conf", dstRoot, "/escape/", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dstRoot, "etc", "a.conf")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHostPath(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"-":           "-",
+		"/etc/app":    "/etc/app",
+		"./app.conf":  filepath.Join(cwd, "app.conf"),
+		"logs":        filepath.Join(cwd, "logs"),
+		"logs/":       filepath.Join(cwd, "logs") + "/",
+		"logs/.":      filepath.Join(cwd, "logs") + "/.",
+		"../app.conf": filepath.Join(filepath.Dir(cwd), "app.conf"),
+	}
+	for path, expect := range cases {
+		if got, err := HostPath(path); err != nil || got != expect {
+			t.Fatalf("HostPath %s got %s error %v, expect %s", path, got, err, expect)
+		}
+	}
+}
+
+func TestCopyPathRelativeHostPath(t *testing.T) {
+	hostDir := t.TempDir()
+	containerRoot := t.TempDir()
+	writeTestFile(t, filepath.Join(hostDir, "app.conf"))
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	if err := os.Chdir(hostDir); err != nil {
+		t.Fatal(err)
+	}
+	// 宿主机的相对路径基于当前目录，而不是 "/"
+	src, err := HostPath("./app.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyPath("/", src, containerRoot, "/app.conf", false); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := HostPath("copied.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyPath(containerRoot, "/app.conf", "/", dst, false); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(hostDir, "copied.conf")); err != nil || string(content) != "test" {
+		t.Fatalf("read copied.conf %s error %v", content, err)
+	}
+}
@@ -0,0 +1,134 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// 文件系统变更类型，与 docker diff 的输出保持一致
+const (
+	ChangeAdd    = "A"
+	ChangeModify = "C"
+	ChangeDelete = "D"
+)
+
+const (
+	// aufs 使用 .wh.<name> 标记删除的文件
+	aufsWhiteoutPrefix = ".wh."
+	// aufs 内部元数据文件（.wh..wh.aufs、.wh..wh.plnk 等）的前缀
+	aufsWhiteoutMetaPrefix = ".wh..wh."
+	// aufs 使用 .wh..wh..opq 标记目录为 opaque，即屏蔽下层同名目录的内容
+	aufsOpaqueMarker = ".wh..wh..opq"
+	// overlay 使用该 xattr 标记 opaque 目录
+	overlayOpaqueXattr = "trusted.overlay.opaque"
+)
+
+// 容器可写层相对于镜像的一条变更记录
+type Change struct {
+	Path string `json:"path"` // 容器内的路径
+	Kind string `json:"kind"` // 变更类型 A/C/D
+}
+
+// 计算容器可写层相对于镜像只读层的变更
+func ContainerChanges(containerId, imageName string) ([]Change, error) {
+	writeURL := fmt.Sprintf(WriteLayerUrl, containerId)
+	if exist, err := pathExists(writeURL); err != nil || !exist {
+		return nil, fmt.Errorf("write layer %s not found: %v", writeURL, err)
+	}
+	return layerChanges(writeURL, imageLayerUrl(imageName))
+}
+
+// 遍历可写层，解析 aufs / overlay 的 whiteout 与 opaque 目录，得到变更列表
+func layerChanges(layerDir, imageDir string) ([]Change, error) {
+	changes := map[string]string{}
+	// 标记 opaque 目录下被屏蔽的镜像文件为删除
+	addOpaqueDeletes := func(dir string) error {
+		entries, err := ioutil.ReadDir(filepath.Join(imageDir, dir))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		for _, entry := range entries {
+			p := filepath.Join(dir, entry.Name())
+			if _, err := os.Lstat(filepath.Join(layerDir, p)); os.IsNotExist(err) {
+				changes[p] = ChangeDelete
+			}
+		}
+		return nil
+	}
+
+	err := filepath.Walk(layerDir, func(p string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(layerDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		path := "/" + rel
+		dir, name := filepath.Split(path)
+		// aufs 的元数据文件不属于容器内容
+		if strings.HasPrefix(name, aufsWhiteoutMetaPrefix) {
+			if name == aufsOpaqueMarker {
+				return addOpaqueDeletes(dir)
+			}
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// aufs 删除标记
+		if strings.HasPrefix(name, aufsWhiteoutPrefix) {
+			changes[filepath.Join(dir, strings.TrimPrefix(name, aufsWhiteoutPrefix))] = ChangeDelete
+			return nil
+		}
+		// overlay 删除标记：主次设备号均为 0 的字符设备
+		if isOverlayWhiteout(f) {
+			changes[path] = ChangeDelete
+			return nil
+		}
+		// 镜像中已存在的路径为修改，否则为新增
+		kind := ChangeAdd
+		if _, err := os.Lstat(filepath.Join(imageDir, rel)); err == nil {
+			kind = ChangeModify
+		}
+		changes[path] = kind
+		if f.IsDir() && isOverlayOpaque(p) {
+			return addOpaqueDeletes(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Change, 0, len(changes))
+	for path, kind := range changes {
+		result = append(result, Change{Path: path, Kind: kind})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result, nil
+}
+
+func isOverlayWhiteout(f os.FileInfo) bool {
+	if f.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	stat, ok := f.Sys().(*syscall.Stat_t)
+	return ok && stat.Rdev == 0
+}
+
+func isOverlayOpaque(path string) bool {
+	buf := make([]byte, 1)
+	n, err := syscall.Getxattr(path, overlayOpaqueXattr, buf)
+	return err == nil && n == 1 && buf[0] == 'y'
+}
@@ -0,0 +1,59 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLayerChangesAufs(t *testing.T) {
+	imageDir := t.TempDir()
+	layerDir := t.TempDir()
+	writeTestFile(t, filepath.Join(imageDir, "etc", "hosts"))
+	writeTestFile(t, filepath.Join(imageDir, "bin", "sh"))
+	writeTestFile(t, filepath.Join(imageDir, "var", "cache", "a"))
+	writeTestFile(t, filepath.Join(imageDir, "var", "cache", "b"))
+
+	// 修改 /etc/hosts，新增 /tmp/new，删除 /bin/sh，清空 /var/cache 后重建 b
+	writeTestFile(t, filepath.Join(layerDir, "etc", "hosts"))
+	writeTestFile(t, filepath.Join(layerDir, "tmp", "new"))
+	writeTestFile(t, filepath.Join(layerDir, "bin", ".wh.sh"))
+	writeTestFile(t, filepath.Join(layerDir, "var", "cache", ".wh..wh..opq"))
+	writeTestFile(t, filepath.Join(layerDir, "var", "cache", "b"))
+	writeTestFile(t, filepath.Join(layerDir, ".wh..wh.plnk", "1.2"))
+
+	changes, err := layerChanges(layerDir, imageDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []Change{
+		{"/bin", ChangeModify},
+		{"/bin/sh", ChangeDelete},
+		{"/etc", ChangeModify},
+		{"/etc/hosts", ChangeModify},
+		{"/tmp", ChangeAdd},
+		{"/tmp/new", ChangeAdd},
+		{"/var", ChangeModify},
+		{"/var/cache", ChangeModify},
+		{"/var/cache/a", ChangeDelete},
+		{"/var/cache/b", ChangeModify},
+	}
+	if len(changes) != len(expect) {
+		t.Fatalf("changes %v, expect %v", changes, expect)
+	}
+	for i := range expect {
+		if changes[i] != expect[i] {
+			t.Fatalf("change %d is %v, expect %v", i, changes[i], expect[i])
+		}
+	}
+}
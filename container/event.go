@@ -0,0 +1,193 @@
+package container
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 记录事件的文件名，位于容器信息的存放目录下
+const EventsFile = "events.log"
+
+// 事件的对象类型
+const (
+	ContainerEventType = "container"
+	NetworkEventType   = "network"
+)
+
+// 容器与网络的生命周期事件，字段与 docker events 的 JSON 输出保持一致
+type Event struct {
+	Type     string     `json:"Type"`
+	Action   string     `json:"Action"`
+	Actor    EventActor `json:"Actor"`
+	Time     int64      `json:"time"`
+	TimeNano int64      `json:"timeNano"`
+}
+
+// 产生事件的对象，容器事件的 ID 为容器 ID，网络事件的 ID 为网络名
+type EventActor struct {
+	ID         string            `json:"ID"`
+	Attributes map[string]string `json:"Attributes"`
+}
+
+// 事件文件的路径
+func EventsPath() string {
+	return StateDir + "/" + EventsFile
+}
+
+// 记录容器事件，属性中包含容器名、镜像与容器的标签
+func LogContainerEvent(info *Info, action string, attributes map[string]string) {
+	attrs := map[string]string{}
+	for key, value := range info.Labels {
+		attrs[key] = value
+	}
+	for key, value := range attributes {
+		attrs[key] = value
+	}
+	attrs["name"] = info.Name
+	attrs["image"] = info.Image
+	LogEvent(ContainerEventType, action, info.Id, attrs)
+}
+
+// 记录网络事件
+func LogNetworkEvent(networkName, action string, attributes map[string]string) {
+	attrs := map[string]string{"name": networkName}
+	for key, value := range attributes {
+		attrs[key] = value
+	}
+	LogEvent(NetworkEventType, action, networkName, attrs)
+}
+
+// 追加一条事件，每个事件占一行并通过一次 write 写入，多个进程同时追加时不会交错；记录失败不影响容器操作
+func LogEvent(eventType, action, id string, attributes map[string]string) {
+	now := time.Now()
+	event := Event{
+		Type:     eventType,
+		Action:   action,
+		Actor:    EventActor{ID: id, Attributes: attributes},
+		Time:     now.Unix(),
+		TimeNano: now.UnixNano(),
+	}
+	content, err := json.Marshal(event)
+	if err != nil {
+		log.Errorf("Marshal event error %v", err)
+		return
+	}
+	if err := os.MkdirAll(StateDir, 0622); err != nil {
+		log.Errorf("Mkdir %s error %v", StateDir, err)
+		return
+	}
+	file, err := os.OpenFile(EventsPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		log.Errorf("Open events file error %v", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(content, '\n')); err != nil {
+		log.Errorf("Write event error %v", err)
+	}
+}
+
+// 查找容器最近一次的 action 事件，没有找到时返回 nil
+func LastContainerEvent(containerId, action string) (*Event, error) {
+	file, err := os.Open(EventsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	var last *Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if event.Type == ContainerEventType && event.Action == action && event.Actor.ID == containerId {
+			last = &event
+		}
+	}
+	return last, scanner.Err()
+}
+
+/*
+解析 events --since/--until 的时间：
+	1. Unix 时间戳，可以带小数部分
+	2. RFC3339 格式或者 TimeFormat 格式的本地时间
+	3. 相对于 now 的时间段，例如 10m 表示 10 分钟之前
+*/
+func ParseEventTime(value string, now time.Time) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(TimeFormat, value, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s'", value)
+}
+
+// events 支持的过滤条件
+var eventFilterKeys = map[string]bool{
+	"container": true,
+	"network":   true,
+	"event":     true,
+	"type":      true,
+	"label":     true,
+}
+
+// 解析 events 的过滤条件
+func ParseEventFilters(args []string) (Filter, error) {
+	return parseFilters(args, eventFilterKeys)
+}
+
+// 判断事件是否满足所有过滤条件
+func (f Filter) MatchEvent(event *Event) bool {
+	for key, values := range f {
+		matched := false
+		for _, value := range values {
+			if matchEventFilter(event, key, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func matchEventFilter(event *Event, key, value string) bool {
+	switch key {
+	case "container":
+		// 容器可以通过 ID 前缀或者容器名指定
+		return event.Type == ContainerEventType &&
+			(strings.HasPrefix(event.Actor.ID, value) || event.Actor.Attributes["name"] == value)
+	case "network":
+		return event.Type == NetworkEventType && event.Actor.ID == value
+	case "event":
+		return event.Action == value
+	case "type":
+		return event.Type == value
+	case "label":
+		parts := strings.SplitN(value, "=", 2)
+		labelValue, ok := event.Actor.Attributes[parts[0]]
+		return ok && (len(parts) == 1 || labelValue == parts[1])
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package container
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEventTime(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)
+	valid := map[string]time.Time{
+		"1577934245":           time.Unix(1577934245, 0),
+		"1577934245.5":         time.Unix(1577934245, 500000000),
+		"2020-01-02T03:04:05Z": time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		"2020-01-02 03:00:00":  time.Date(2020, 1, 2, 3, 0, 0, 0, time.Local),
+		"10m":                  now.Add(-10 * time.Minute),
+	}
+	for value, expect := range valid {
+		if got, err := ParseEventTime(value, now); err != nil || !got.Equal(expect) {
+			t.Fatalf("ParseEventTime %s got %v error %v, expect %v", value, got, err, expect)
+		}
+	}
+	for _, value := range []string{"", "yesterday", "10x"} {
+		if _, err := ParseEventTime(value, now); err == nil {
+			t.Fatalf("ParseEventTime %s should fail", value)
+		}
+	}
+}
+
+func TestEventFilterMatch(t *testing.T) {
+	event := &Event{Type: ContainerEventType, Action: "start", Actor: EventActor{ID: "abc123",
+		Attributes: map[string]string{"name": "web", "image": "busybox", "team": "infra"}}}
+	cases := map[string]bool{
+		"container=abc":                true,
+		"container=web":                true,
+		"container=we":                 false,
+		"event=start":                  true,
+		"event=die,event=start":        true,
+		"event=die":                    false,
+		"type=container":               true,
+		"type=network":                 false,
+		"network=abc123":               false,
+		"label=team=infra":             true,
+		"label=team=web":               false,
+		"container=web,event=destroy":  false,
+		"container=abc,type=container": true,
+	}
+	for args, expect := range cases {
+		filter, err := ParseEventFilters(strings.Split(args, ","))
+		if err != nil {
+			t.Fatalf("ParseEventFilters %s error %v", args, err)
+		}
+		if filter.MatchEvent(event) != expect {
+			t.Fatalf("filter %s match expect %v", args, expect)
+		}
+	}
+	if _, err := ParseEventFilters([]string{"status=running"}); err == nil {
+		t.Fatalf("ParseEventFilters status should fail")
+	}
+}
+
+func TestLogContainerEvent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ydocker-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer SetStateDir(StateDir)
+	SetStateDir(dir)
+
+	info := &Info{Id: "abc123", Name: "web", Image: "busybox", Labels: map[string]string{"team": "infra"}}
+	LogContainerEvent(info, "die", map[string]string{"exitCode": "1"})
+	LogNetworkEvent("bridge0", "connect", map[string]string{"container": info.Id})
+
+	content, err := ioutil.ReadFile(EventsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expect 2 events, got %d", len(lines))
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil {
+		t.Fatal(err)
+	}
+	attrs := event.Actor.Attributes
+	if event.Type != ContainerEventType || event.Action != "die" || event.Actor.ID != "abc123" ||
+		attrs["name"] != "web" || attrs["exitCode"] != "1" || attrs["team"] != "infra" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != NetworkEventType || event.Actor.ID != "bridge0" || event.Actor.Attributes["container"] != "abc123" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+}
+
+func TestLastContainerEvent(t *testing.T) {
+	defer SetStateDir(StateDir)
+	SetStateDir(t.TempDir())
+	if event, err := LastContainerEvent("abc123", "die"); err != nil || event != nil {
+		t.Fatalf("missing events file got %+v error %v", event, err)
+	}
+	info := &Info{Id: "abc123", Name: "web"}
+	LogContainerEvent(info, "die", map[string]string{"exitCode": "1"})
+	LogContainerEvent(&Info{Id: "def456"}, "die", map[string]string{"exitCode": "2"})
+	LogContainerEvent(info, "die", map[string]string{"exitCode": "3"})
+	LogContainerEvent(info, "destroy", nil)
+	event, err := LastContainerEvent("abc123", "die")
+	if err != nil || event == nil || event.Actor.Attributes["exitCode"] != "3" {
+		t.Fatalf("LastContainerEvent got %+v error %v", event, err)
+	}
+}
@@ -0,0 +1,87 @@
+package container
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ps 等命令的过滤条件，同一个 key 的多个值之间为或，不同 key 之间为且
+type Filter map[string][]string
+
+// 支持的过滤条件
+var filterKeys = map[string]bool{
+	"id":      true,
+	"name":    true,
+	"status":  true,
+	"network": true,
+	"label":   true,
+	"health":  true,
+}
+
+// 解析 key=value 形式的容器过滤条件
+func ParseFilters(args []string) (Filter, error) {
+	return parseFilters(args, filterKeys)
+}
+
+func parseFilters(args []string, keys map[string]bool) (Filter, error) {
+	filter := Filter{}
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("bad format of filter (expected name=value): %s", arg)
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		if !keys[key] {
+			return nil, fmt.Errorf("invalid filter '%s'", key)
+		}
+		filter[key] = append(filter[key], parts[1])
+	}
+	return filter, nil
+}
+
+// 是否包含指定的过滤条件
+func (f Filter) Has(key string) bool {
+	return len(f[key]) > 0
+}
+
+// 判断容器是否满足所有过滤条件
+func (f Filter) Match(info *Info) bool {
+	for key, values := range f {
+		matched := false
+		for _, value := range values {
+			if matchFilter(info, key, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func matchFilter(info *Info, key, value string) bool {
+	switch key {
+	case "id":
+		return strings.HasPrefix(info.Id, value)
+	case "name":
+		return strings.Contains(info.Name, value)
+	case "status":
+		return info.Status == value
+	case "network":
+		return info.Network == value
+	case "label":
+		// label=key 只要求存在该标签，label=key=value 要求标签的值相等
+		parts := strings.SplitN(value, "=", 2)
+		labelValue, ok := info.Labels[parts[0]]
+		return ok && (len(parts) == 1 || labelValue == parts[1])
+	case "health":
+		// 没有健康检查的容器为 none
+		if info.Health == nil {
+			return value == HealthNone
+		}
+		return info.Health.Status == value
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package container
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	info := &Info{Id: "abc123", Name: "web-1", Status: RUNNING, Network: "bridge0",
+		Labels: map[string]string{"team": "infra", "job": "build"}, Health: &Health{Status: Healthy}}
+	cases := map[string]bool{
+		"id=abc":                       true,
+		"id=bc":                        false,
+		"name=web":                     true,
+		"status=running":               true,
+		"status=exited":                false,
+		"network=bridge0":              true,
+		"status=exited,status=running": true,
+		"status=running,name=db":       false,
+		"label=team":                   true,
+		"label=team=infra":             true,
+		"label=team=web":               false,
+		"label=team=web,label=job":     true,
+		"label=owner":                  false,
+		"health=healthy":               true,
+		"health=none":                  false,
+	}
+	for args, expect := range cases {
+		filter, err := ParseFilters(strings.Split(args, ","))
+		if err != nil {
+			t.Fatalf("ParseFilters %s error %v", args, err)
+		}
+		if filter.Match(info) != expect {
+			t.Fatalf("filter %s match expect %v", args, expect)
+		}
+	}
+	for _, arg := range []string{"status", "foo=bar", "name="} {
+		if _, err := ParseFilters([]string{arg}); err == nil {
+			t.Fatalf("ParseFilters %s should fail", arg)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 容器的健康状态
+const (
+	HealthNone      = "none"
+	HealthStarting  = "starting"
+	Healthy         = "healthy"
+	Unhealthy       = "unhealthy"
+	healthLogLength = 5    // 保留最近几次检查的结果
+	healthOutputMax = 4096 // 每次检查保留的输出长度
+)
+
+const (
+	DefaultHealthInterval = 30 * time.Second
+	DefaultHealthTimeout  = 30 * time.Second
+	DefaultHealthRetries  = 3
+)
+
+/*
+健康检查配置，与 docker 一样 Test 的第一个元素表示检查的方式：
+	NONE 禁用镜像中的健康检查
+	CMD 之后的元素为要执行的命令与参数
+	CMD-SHELL 之后的元素为通过 /bin/sh 执行的命令
+*/
+type HealthConfig struct {
+	Test        []string      `json:"test,omitempty"`
+	Interval    time.Duration `json:"interval,omitempty"`    // 两次检查的间隔
+	Timeout     time.Duration `json:"timeout,omitempty"`     // 单次检查的超时时间
+	StartPeriod time.Duration `json:"startPeriod,omitempty"` // 容器启动后的这段时间内检查失败不计入重试次数
+	Retries     int           `json:"retries,omitempty"`     // 连续失败多少次后变为 unhealthy
+}
+
+// 一次健康检查的结果
+type HealthResult struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	ExitCode int    `json:"exitCode"`
+	Output   string `json:"output"`
+}
+
+// 容器的健康状态与最近的检查结果
+type Health struct {
+	Status        string         `json:"status"`
+	FailingStreak int            `json:"failingStreak"`
+	Log           []HealthResult `json:"log"`
+}
+
+// 是否需要执行健康检查
+func (c *HealthConfig) Enabled() bool {
+	return c != nil && len(c.Test) > 1 && (c.Test[0] == "CMD" || c.Test[0] == "CMD-SHELL")
+}
+
+// 合并健康检查配置，override 中设置的字段覆盖当前配置，返回新的配置
+func (c *HealthConfig) Merge(override *HealthConfig) *HealthConfig {
+	merged := &HealthConfig{}
+	if c != nil {
+		*merged = *c
+	}
+	if override == nil {
+		return merged
+	}
+	if len(override.Test) > 0 {
+		merged.Test = override.Test
+	}
+	if override.Interval > 0 {
+		merged.Interval = override.Interval
+	}
+	if override.Timeout > 0 {
+		merged.Timeout = override.Timeout
+	}
+	if override.StartPeriod > 0 {
+		merged.StartPeriod = override.StartPeriod
+	}
+	if override.Retries > 0 {
+		merged.Retries = override.Retries
+	}
+	return merged
+}
+
+// 检查间隔，未设置时使用默认值
+func (c *HealthConfig) IntervalOrDefault() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return DefaultHealthInterval
+}
+
+// 检查超时时间，未设置时使用默认值
+func (c *HealthConfig) TimeoutOrDefault() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultHealthTimeout
+}
+
+// 重试次数，未设置时使用默认值
+func (c *HealthConfig) RetriesOrDefault() int {
+	if c.Retries > 0 {
+		return c.Retries
+	}
+	return DefaultHealthRetries
+}
+
+// 在容器中执行的检查命令，exec 通过 shell 执行命令，CMD 形式的参数需要转义
+func (c *HealthConfig) ShellCommand() string {
+	if c.Test[0] == "CMD-SHELL" {
+		return c.Test[1]
+	}
+	args := make([]string, 0, len(c.Test)-1)
+	for _, arg := range c.Test[1:] {
+		args = append(args, shellQuote(arg))
+	}
+	return strings.Join(args, " ")
+}
+
+// 使用单引号转义 shell 参数
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,") == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+/*
+记录一次检查结果并更新健康状态，返回状态是否发生变化
+	1. 检查成功时变为 healthy 并清零连续失败次数
+	2. 检查失败时连续失败次数加一，达到 retries 后变为 unhealthy
+	3. 启动阶段（startPeriod 内）的失败不计入连续失败次数
+*/
+func (h *Health) Record(result HealthResult, retries int, starting bool) bool {
+	if len(result.Output) > healthOutputMax {
+		result.Output = result.Output[:healthOutputMax]
+	}
+	h.Log = append(h.Log, result)
+	if len(h.Log) > healthLogLength {
+		h.Log = h.Log[len(h.Log)-healthLogLength:]
+	}
+	previous := h.Status
+	switch {
+	case result.ExitCode == 0:
+		h.Status = Healthy
+		h.FailingStreak = 0
+	case starting:
+	default:
+		h.FailingStreak++
+		if h.FailingStreak >= retries {
+			h.Status = Unhealthy
+		}
+	}
+	return h.Status != previous
+}
+
+/*
+解析镜像配置中的 HEALTHCHECK：
+	HEALTHCHECK NONE
+	HEALTHCHECK [--interval=30s] [--timeout=30s] [--start-period=0s] [--retries=3] CMD command
+*/
+func ParseHealthcheck(value string) (*HealthConfig, error) {
+	if strings.ToUpper(strings.TrimSpace(value)) == "NONE" {
+		return &HealthConfig{Test: []string{"NONE"}}, nil
+	}
+	config := &HealthConfig{}
+	rest := strings.TrimSpace(value)
+	for strings.HasPrefix(rest, "--") {
+		parts := strings.SplitN(rest, " ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("missing command in HEALTHCHECK %q", value)
+		}
+		option := strings.SplitN(strings.TrimPrefix(parts[0], "--"), "=", 2)
+		if len(option) != 2 {
+			return nil, fmt.Errorf("invalid HEALTHCHECK option %s", parts[0])
+		}
+		if option[0] == "retries" {
+			if _, err := fmt.Sscanf(option[1], "%d", &config.Retries); err != nil || config.Retries < 1 {
+				return nil, fmt.Errorf("invalid HEALTHCHECK retries %s", option[1])
+			}
+		} else {
+			d, err := time.ParseDuration(option[1])
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid HEALTHCHECK %s %s", option[0], option[1])
+			}
+			switch option[0] {
+			case "interval":
+				config.Interval = d
+			case "timeout":
+				config.Timeout = d
+			case "start-period":
+				config.StartPeriod = d
+			default:
+				return nil, fmt.Errorf("unknown HEALTHCHECK option --%s", option[0])
+			}
+		}
+		rest = strings.TrimSpace(parts[1])
+	}
+	parts := strings.SplitN(rest, " ", 2)
+	if len(parts) != 2 || strings.ToUpper(parts[0]) != "CMD" || strings.TrimSpace(parts[1]) == "" {
+		return nil, fmt.Errorf("HEALTHCHECK requires CMD command: %q", value)
+	}
+	command := strings.TrimSpace(parts[1])
+	if strings.HasPrefix(command, "[") {
+		var args []string
+		if err := json.Unmarshal([]byte(command), &args); err != nil || len(args) == 0 {
+			return nil, fmt.Errorf("parse HEALTHCHECK command %s error: %v", command, err)
+		}
+		config.Test = append([]string{"CMD"}, args...)
+	} else {
+		config.Test = []string{"CMD-SHELL", command}
+	}
+	return config, nil
+}
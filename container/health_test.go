@@ -0,0 +1,92 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseHealthcheck(t *testing.T) {
+	valid := map[string]*HealthConfig{
+		"NONE":                          {Test: []string{"NONE"}},
+		"CMD curl -f http://localhost/": {Test: []string{"CMD-SHELL", "curl -f http://localhost/"}},
+		`--interval=5s --retries=2 CMD ["cat", "/tmp/ok"]`: {
+			Test: []string{"CMD", "cat", "/tmp/ok"}, Interval: 5 * time.Second, Retries: 2},
+		"--timeout=1s --start-period=1m cmd true": {
+			Test: []string{"CMD-SHELL", "true"}, Timeout: time.Second, StartPeriod: time.Minute},
+	}
+	for value, expect := range valid {
+		config, err := ParseHealthcheck(value)
+		if err != nil || !reflect.DeepEqual(config, expect) {
+			t.Fatalf("ParseHealthcheck %s got %+v error %v, expect %+v", value, config, err, expect)
+		}
+	}
+	for _, value := range []string{"true", "CMD", "--interval=5s", "--interval=x CMD true",
+		"--retries=0 CMD true", "--foo=1s CMD true", `CMD ["true"`} {
+		if _, err := ParseHealthcheck(value); err == nil {
+			t.Fatalf("ParseHealthcheck %s should fail", value)
+		}
+	}
+}
+
+func TestHealthConfigMerge(t *testing.T) {
+	image := &HealthConfig{Test: []string{"CMD", "true"}, Interval: time.Second, Retries: 5}
+	merged := image.Merge(&HealthConfig{Test: []string{"CMD-SHELL", "false"}, Timeout: 2 * time.Second})
+	expect := &HealthConfig{Test: []string{"CMD-SHELL", "false"}, Interval: time.Second, Timeout: 2 * time.Second, Retries: 5}
+	if !reflect.DeepEqual(merged, expect) {
+		t.Fatalf("merged %+v, expect %+v", merged, expect)
+	}
+	if !merged.Enabled() {
+		t.Fatal("merged config should be enabled")
+	}
+	var none *HealthConfig
+	if none.Merge(&HealthConfig{Interval: time.Second}).Enabled() {
+		t.Fatal("config without test should be disabled")
+	}
+	if (&HealthConfig{Test: []string{"NONE"}}).Enabled() {
+		t.Fatal("NONE should be disabled")
+	}
+	if merged.IntervalOrDefault() != time.Second || (&HealthConfig{}).TimeoutOrDefault() != DefaultHealthTimeout {
+		t.Fatal("unexpected default")
+	}
+}
+
+func TestHealthConfigShellCommand(t *testing.T) {
+	cases := map[string][]string{
+		"test -f /tmp/ok":            {"CMD-SHELL", "test -f /tmp/ok"},
+		"cat /tmp/ok":                {"CMD", "cat", "/tmp/ok"},
+		`echo 'a b' '' 'it'\''s'`:    {"CMD", "echo", "a b", "", "it's"},
+		"wget -q http://host:80/x=1": {"CMD", "wget", "-q", "http://host:80/x=1"},
+	}
+	for expect, test := range cases {
+		if cmd := (&HealthConfig{Test: test}).ShellCommand(); cmd != expect {
+			t.Fatalf("ShellCommand %v got %s, expect %s", test, cmd, expect)
+		}
+	}
+}
+
+func TestHealthRecord(t *testing.T) {
+	health := &Health{Status: HealthStarting}
+	fail := HealthResult{ExitCode: 1}
+	if health.Record(fail, 2, true) || health.Status != HealthStarting || health.FailingStreak != 0 {
+		t.Fatalf("failure in start period should not count, got %+v", health)
+	}
+	if !health.Record(HealthResult{}, 2, true) || health.Status != Healthy {
+		t.Fatalf("expect healthy, got %+v", health)
+	}
+	if health.Record(fail, 2, false) || health.Status != Healthy || health.FailingStreak != 1 {
+		t.Fatalf("expect still healthy, got %+v", health)
+	}
+	if !health.Record(fail, 2, false) || health.Status != Unhealthy || health.FailingStreak != 2 {
+		t.Fatalf("expect unhealthy, got %+v", health)
+	}
+	for i := 0; i < 10; i++ {
+		health.Record(fail, 2, false)
+	}
+	if len(health.Log) != healthLogLength {
+		t.Fatalf("expect %d results in log, got %d", healthLogLength, len(health.Log))
+	}
+	if !health.Record(HealthResult{}, 2, false) || health.Status != Healthy || health.FailingStreak != 0 {
+		t.Fatalf("expect healthy again, got %+v", health)
+	}
+}
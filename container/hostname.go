@@ -0,0 +1,128 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+const (
+	// 容器目录下生成的 /etc/hostname 与 /etc/hosts
+	HostnameFile = "hostname"
+	HostsFile    = "hosts"
+	// sethostname 与 setdomainname 允许的最大长度
+	maxHostnameLength = 64
+)
+
+// RFC 1123 中的主机名，由 . 分隔的字母、数字与 - 组成
+var hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// 检查 --hostname 与 --domainname 是否是合法的主机名
+func ValidateHostname(name string) error {
+	if len(name) > maxHostnameLength || !hostnamePattern.MatchString(name) {
+		return fmt.Errorf("invalid hostname '%s'", name)
+	}
+	return nil
+}
+
+// 容器的完整域名，没有 domainname 时为 hostname
+func (info *Info) FQDN() string {
+	if info.Domainname == "" {
+		return info.Hostname
+	}
+	return info.Hostname + "." + info.Domainname
+}
+
+/*
+生成容器的 /etc/hosts：
+	1. 包含 localhost 与 IPv6 的常用条目
+	2. 容器连接网络时把分配的 IP 解析为容器的主机名，否则与 Debian 一样使用 127.0.1.1
+*/
+func HostsContent(info *Info) string {
+	ip := info.IPAddress
+	if ip == "" {
+		ip = "127.0.1.1"
+	}
+	names := info.Hostname
+	if fqdn := info.FQDN(); fqdn != info.Hostname {
+		names = fqdn + " " + info.Hostname
+	}
+	lines := []string{
+		"127.0.0.1\tlocalhost",
+		"::1\tlocalhost ip6-localhost ip6-loopback",
+		"fe00::0\tip6-localnet",
+		"ff00::0\tip6-mcastprefix",
+		"ff02::1\tip6-allnodes",
+		"ff02::2\tip6-allrouters",
+		ip + "\t" + names,
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// 在容器目录下生成 /etc/hostname 与 /etc/hosts，返回容器中的路径到宿主机文件的映射
+func WriteHostsFiles(info *Info) (map[string]string, error) {
+	dirURL := fmt.Sprintf(DefaultInfoLocation, info.Id)
+	files := map[string]string{
+		"/etc/hostname": filepath.Join(dirURL, HostnameFile),
+		"/etc/hosts":    filepath.Join(dirURL, HostsFile),
+	}
+	contents := map[string]string{
+		"/etc/hostname": info.Hostname + "\n",
+		"/etc/hosts":    HostsContent(info),
+	}
+	for target, source := range files {
+		if err := ioutil.WriteFile(source, []byte(contents[target]), 0644); err != nil {
+			return nil, fmt.Errorf("write %s error: %v", source, err)
+		}
+	}
+	return files, nil
+}
+
+/*
+把宿主机上的文件绑定挂载到容器根目录 root 中，需要在 pivot_root 之前调用：
+	1. 此时镜像中的符号链接会按宿主机的根目录解析，通过 SecureJoin 将目标路径限定在 root 中
+	2. 镜像中没有该文件时创建空文件作为挂载点，目标不是 root 中的普通文件时拒绝挂载
+*/
+func bindMountFiles(root string, files map[string]string) error {
+	for target, source := range files {
+		targetPath, err := SecureJoin(root, target)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(targetPath, os.O_CREATE|os.O_RDONLY|syscall.O_NOFOLLOW, 0644)
+		if err != nil {
+			return fmt.Errorf("create mount point %s error: %v", target, err)
+		}
+		_ = file.Close()
+		// 创建过程中路径可能被替换为符号链接或目录，挂载前再次检查
+		if fi, err := os.Lstat(targetPath); err != nil || !fi.Mode().IsRegular() {
+			return fmt.Errorf("refuse to mount %s: %s is not a regular file in rootfs", source, target)
+		}
+		if err := syscall.Mount(source, targetPath, "bind", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("bind mount %s to %s error: %v", source, target, err)
+		}
+	}
+	return nil
+}
+
+// 在容器的 UTS Namespace 中设置主机名与域名
+func setHostname(hostname, domainname string) error {
+	if hostname != "" {
+		if err := syscall.Sethostname([]byte(hostname)); err != nil {
+			return fmt.Errorf("sethostname error: %v", err)
+		}
+	}
+	if domainname != "" {
+		if err := syscall.Setdomainname([]byte(domainname)); err != nil {
+			return fmt.Errorf("setdomainname error: %v", err)
+		}
+	}
+	return nil
+}
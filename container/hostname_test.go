@@ -0,0 +1,97 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestValidateHostname(t *testing.T) {
+	for _, name := range []string{"web", "web-1", "a", "db.internal", "0abc"} {
+		if err := ValidateHostname(name); err != nil {
+			t.Fatalf("ValidateHostname %s error %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "-web", "web-", "web_1", "a..b", "web.", strings.Repeat("a", 65)} {
+		if err := ValidateHostname(name); err == nil {
+			t.Fatalf("ValidateHostname %s should fail", name)
+		}
+	}
+}
+
+func TestHostsContent(t *testing.T) {
+	info := &Info{Hostname: "web", Domainname: "example.com", IPAddress: "10.0.1.2"}
+	if info.FQDN() != "web.example.com" {
+		t.Fatalf("FQDN got %s", info.FQDN())
+	}
+	if !strings.HasSuffix(HostsContent(info), "\n10.0.1.2\tweb.example.com web\n") {
+		t.Fatalf("HostsContent got %s", HostsContent(info))
+	}
+	info = &Info{Hostname: "abc123"}
+	if !strings.HasPrefix(HostsContent(info), "127.0.0.1\tlocalhost\n") ||
+		!strings.HasSuffix(HostsContent(info), "\n127.0.1.1\tabc123\n") {
+		t.Fatalf("HostsContent without ip got %s", HostsContent(info))
+	}
+}
+
+func TestWriteHostsFiles(t *testing.T) {
+	defer SetStateDir(StateDir)
+	SetStateDir(t.TempDir())
+	info := &Info{Id: "abc123", Hostname: "web"}
+	if err := os.MkdirAll(filepath.Join(StateDir, info.Id), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files, err := WriteHostsFiles(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(files["/etc/hostname"])
+	if err != nil || string(content) != "web\n" {
+		t.Fatalf("hostname file got %q error %v", content, err)
+	}
+	content, err = ioutil.ReadFile(files["/etc/hosts"])
+	if err != nil || string(content) != HostsContent(info) {
+		t.Fatalf("hosts file got %q error %v", content, err)
+	}
+}
+
+func TestBindMountFilesInRootfs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("bind mount requires root")
+	}
+	root := t.TempDir()
+	source := filepath.Join(t.TempDir(), "hosts")
+	if err := ioutil.WriteFile(source, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 镜像中 /etc 为指向绝对路径的符号链接，不能在宿主机上创建文件
+	hostDir := filepath.Join(os.TempDir(), "ydocker-bind-"+filepath.Base(root))
+	defer os.RemoveAll(hostDir)
+	if err := os.Symlink(hostDir, filepath.Join(root, "etc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bindMountFiles(root, map[string]string{"/etc/hosts": source}); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(root, hostDir, "hosts")
+	defer syscall.Unmount(target, syscall.MNT_DETACH)
+	if content, err := ioutil.ReadFile(target); err != nil || string(content) != "127.0.0.1\tlocalhost\n" {
+		t.Fatalf("read mounted hosts %q error %v", content, err)
+	}
+	if _, err := os.Lstat(hostDir); !os.IsNotExist(err) {
+		t.Fatalf("bind mount created %s on host", hostDir)
+	}
+
+	// 目标为目录时拒绝挂载
+	dirRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dirRoot, "etc", "hosts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := bindMountFiles(dirRoot, map[string]string{"/etc/hosts": source}); err == nil {
+		syscall.Unmount(filepath.Join(dirRoot, "etc", "hosts"), syscall.MNT_DETACH)
+		t.Fatal("bind mount to directory should fail")
+	}
+}
@@ -0,0 +1,69 @@
+package container
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ps 等命令展示的短 ID 长度
+const ShortIdLength = 12
+
+// 生成 64 位十六进制的随机容器 ID，避免生成全部为数字的短 ID，防止与数字形式的容器名混淆
+func GenerateId() (string, error) {
+	buf := make([]byte, 32)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("generate container id error: %v", err)
+		}
+		id := hex.EncodeToString(buf)
+		if strings.Trim(ShortId(id), "0123456789") != "" {
+			return id, nil
+		}
+	}
+}
+
+// 容器的短 ID
+func ShortId(id string) string {
+	if len(id) > ShortIdLength {
+		return id[:ShortIdLength]
+	}
+	return id
+}
+
+/*
+根据完整 ID、容器名或 ID 前缀查找容器，优先级与 docker 一致：
+	1. 完整 ID
+	2. 容器名
+	3. 唯一匹配的 ID 前缀，匹配多个容器时返回 ambiguous 错误
+*/
+func FindContainer(containers []*Info, ref string) (*Info, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("container name or id is empty")
+	}
+	for _, item := range containers {
+		if item.Id == ref {
+			return item, nil
+		}
+	}
+	for _, item := range containers {
+		if item.Name == ref {
+			return item, nil
+		}
+	}
+	var matched *Info
+	for _, item := range containers {
+		if !strings.HasPrefix(item.Id, ref) {
+			continue
+		}
+		if matched != nil {
+			return nil, fmt.Errorf("multiple containers found with provided prefix: %s (ambiguous)", ref)
+		}
+		matched = item
+	}
+	if matched == nil {
+		return nil, fmt.Errorf("no such container: %s", ref)
+	}
+	return matched, nil
+}
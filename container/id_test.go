@@ -0,0 +1,49 @@
+package container
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerateId(t *testing.T) {
+	id, err := GenerateId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{64}$`).MatchString(id) {
+		t.Fatalf("invalid id %s", id)
+	}
+	if ShortId(id) != id[:12] || ShortId("abc") != "abc" {
+		t.Fatalf("ShortId error %s", ShortId(id))
+	}
+}
+
+func TestFindContainer(t *testing.T) {
+	containers := []*Info{
+		{Id: "abc123" + strings.Repeat("0", 58), Name: "web"},
+		{Id: "abd456" + strings.Repeat("0", 58), Name: "abc"},
+		{Id: "ff0000" + strings.Repeat("0", 58), Name: "db"},
+	}
+	cases := map[string]string{
+		containers[0].Id: "web",
+		"web":            "web",
+		"abc":            "abc",
+		"abc1":           "web",
+		"f":              "db",
+	}
+	for ref, expect := range cases {
+		info, err := FindContainer(containers, ref)
+		if err != nil || info.Name != expect {
+			t.Fatalf("FindContainer %s got %v error %v, expect %s", ref, info, err, expect)
+		}
+	}
+	if _, err := FindContainer(containers, "ab"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatalf("prefix ab should be ambiguous, got %v", err)
+	}
+	for _, ref := range []string{"", "xyz"} {
+		if _, err := FindContainer(containers, ref); err == nil {
+			t.Fatalf("FindContainer %q should fail", ref)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 镜像的默认标签
+const DefaultImageTag = "latest"
+
+// 镜像配置，保存在 RootUrl 下的 ${imageName}.json 中
+type ImageConfig struct {
+	Cmd             []string      `json:"cmd,omitempty"`             // 默认执行的命令
+	Entrypoint      []string      `json:"entrypoint,omitempty"`      // 入口命令，run 时指定的命令作为其参数
+	EntrypointShell bool          `json:"entrypointShell,omitempty"` // shell 形式的 Entrypoint，忽略 Cmd 与 run 时指定的命令
+	Env             []string      `json:"env,omitempty"`             // 默认环境变量
+	StopSignal      string        `json:"stopSignal,omitempty"`      // 停止容器时发送的信号
+	Healthcheck     *HealthConfig `json:"healthcheck,omitempty"`     // 默认的健康检查
+}
+
+/*
+将 name:tag 形式的镜像名转换为 RootUrl 下使用的文件名
+	1. 不带标签或标签为 latest 时直接使用镜像名，与之前的 ${imageName}.tar 保持兼容
+	2. 其他标签使用 name_tag，避免 ":" 与 aufs 的 dirs 参数分隔符冲突
+*/
+func ImageKey(imageName string) string {
+	i := strings.LastIndex(imageName, ":")
+	if i < 0 || strings.Contains(imageName[i:], "/") {
+		return imageName
+	}
+	name, tag := imageName[:i], imageName[i+1:]
+	if tag == "" || tag == DefaultImageTag {
+		return name
+	}
+	return name + "_" + tag
+}
+
+// 镜像 tar 包的路径
+func ImageTarUrl(imageName string) string {
+	return RootUrl + "/" + ImageKey(imageName) + ".tar"
+}
+
+// 镜像解压后作为只读层的目录
+func imageLayerUrl(imageName string) string {
+	return RootUrl + "/" + ImageKey(imageName)
+}
+
+// 镜像配置文件的路径
+func imageConfigUrl(imageName string) string {
+	return RootUrl + "/" + ImageKey(imageName) + ".json"
+}
+
+// 读取镜像配置，没有配置文件的镜像返回空配置
+func LoadImageConfig(imageName string) (*ImageConfig, error) {
+	config := &ImageConfig{}
+	content, err := ioutil.ReadFile(imageConfigUrl(imageName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// 保存镜像配置
+func (c *ImageConfig) Save(imageName string) error {
+	content, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(imageConfigUrl(imageName), content, 0644)
+}
+
+/*
+根据镜像配置得到最终执行的命令：
+	1. 用户指定的命令会覆盖 Cmd 并作为 Entrypoint 的参数
+	2. 与 docker 一样，shell 形式的 Entrypoint 不接收参数，直接使用 Entrypoint
+*/
+func (c *ImageConfig) Command(args []string) []string {
+	if c.EntrypointShell && len(c.Entrypoint) > 0 {
+		return append([]string{}, c.Entrypoint...)
+	}
+	if len(args) == 0 {
+		args = c.Cmd
+	}
+	command := append([]string{}, c.Entrypoint...)
+	return append(command, args...)
+}
+
+/*
+应用一条 Dockerfile 风格的配置修改，目前支持：
+	CMD ["executable","param"] 或 CMD command param
+	ENTRYPOINT ["executable","param"] 或 ENTRYPOINT command param
+	ENV key=value ... 或 ENV key value
+	STOPSIGNAL signal
+	HEALTHCHECK [OPTIONS] CMD command 或 HEALTHCHECK NONE
+*/
+func (c *ImageConfig) ApplyChange(change string) error {
+	parts := strings.SplitN(strings.TrimSpace(change), " ", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+		return fmt.Errorf("invalid change %q", change)
+	}
+	instruction, value := strings.ToUpper(parts[0]), strings.TrimSpace(parts[1])
+	switch instruction {
+	case "CMD":
+		cmd, _, err := parseCommandValue(value)
+		if err != nil {
+			return err
+		}
+		c.Cmd = cmd
+	case "ENTRYPOINT":
+		cmd, shell, err := parseCommandValue(value)
+		if err != nil {
+			return err
+		}
+		c.Entrypoint = cmd
+		c.EntrypointShell = shell
+	case "ENV":
+		pairs, err := parseEnvValue(value)
+		if err != nil {
+			return err
+		}
+		for _, pair := range pairs {
+			c.Env = setEnv(c.Env, pair)
+		}
+	case "STOPSIGNAL":
+		if _, err := ParseSignal(value); err != nil {
+			return err
+		}
+		c.StopSignal = value
+	case "HEALTHCHECK":
+		healthcheck, err := ParseHealthcheck(value)
+		if err != nil {
+			return err
+		}
+		c.Healthcheck = healthcheck
+	default:
+		return fmt.Errorf("unsupported change instruction %s", instruction)
+	}
+	return nil
+}
+
+// 解析 exec 形式（json 数组）或 shell 形式的命令，shell 为 true 表示 shell 形式
+func parseCommandValue(value string) (cmd []string, shell bool, err error) {
+	if strings.HasPrefix(value, "[") {
+		if err := json.Unmarshal([]byte(value), &cmd); err != nil {
+			return nil, false, fmt.Errorf("parse command %s error: %v", value, err)
+		}
+		return cmd, false, nil
+	}
+	return []string{"/bin/sh", "-c", value}, true, nil
+}
+
+/*
+解析 ENV 的值，返回 key=value 列表：
+	1. ENV key value 形式时 key 之后的全部内容作为值
+	2. ENV key=value ... 形式时按空白分隔，值中可以使用引号与 \ 转义包含空白
+*/
+func parseEnvValue(value string) ([]string, error) {
+	fields := strings.Fields(value)
+	if !strings.Contains(fields[0], "=") {
+		return []string{fields[0] + "=" + strings.TrimSpace(strings.TrimPrefix(value, fields[0]))}, nil
+	}
+	words, err := splitQuotedWords(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ENV %s: %v", value, err)
+	}
+	for _, word := range words {
+		if strings.Index(word, "=") <= 0 {
+			return nil, fmt.Errorf("invalid ENV %s: %s is not key=value", value, word)
+		}
+	}
+	return words, nil
+}
+
+// 按空白切分字符串，单引号中的内容原样保留，双引号中与引号外可以使用 \ 转义
+func splitQuotedWords(value string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord, escaped := false, false
+	for _, r := range value {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// 设置环境变量，已经存在同名变量时替换
+func setEnv(env []string, pair string) []string {
+	key := pair[:strings.Index(pair, "=")+1]
+	for i, item := range env {
+		if strings.HasPrefix(item, key) {
+			env[i] = pair
+			return env
+		}
+	}
+	return append(env, pair)
+}
+
+// 从 rootfs 的 tar 流创建单层镜像，并应用配置修改
+func ImportImage(r io.Reader, imageName string, changes []string) error {
+	config := &ImageConfig{}
+	for _, change := range changes {
+		if err := config.ApplyChange(change); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(RootUrl, 0622); err != nil {
+		return err
+	}
+	// 先写入临时文件，导入成功后再替换，导入失败时保留原来的同名镜像
+	imageTar := ImageTarUrl(imageName)
+	tmpImageTar := imageTar + ".tmp"
+	file, err := os.Create(tmpImageTar)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		_ = file.Close()
+		_ = os.Remove(tmpImageTar)
+		return fmt.Errorf("write image %s error: %v", imageTar, err)
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(tmpImageTar)
+		return err
+	}
+	if err := os.Rename(tmpImageTar, imageTar); err != nil {
+		_ = os.Remove(tmpImageTar)
+		return fmt.Errorf("write image %s error: %v", imageTar, err)
+	}
+	// 删除之前解压的同名镜像，下次运行时重新解压
+	if err := os.RemoveAll(imageLayerUrl(imageName)); err != nil {
+		log.Errorf("Remove old image layer %s error %v", imageLayerUrl(imageName), err)
+	}
+	return config.Save(imageName)
+}
+
+/*
+列出 RootUrl 下由 ydocker 管理的镜像，返回值为镜像的文件名，可以直接作为镜像名使用：
+	1. RootUrl 下可能有其他的 tar 包，只有同时存在镜像配置文件的 tar 包才是镜像
+	2. 手动放入的 tar 包仍然可以用来运行容器，但不会被统计或清理
+*/
+func ListImages() ([]string, error) {
+	tars, err := filepath.Glob(RootUrl + "/*.tar")
+	if err != nil {
+		return nil, err
+	}
+	images := make([]string, 0, len(tars))
+	for _, tarPath := range tars {
+		imageName := strings.TrimSuffix(filepath.Base(tarPath), ".tar")
+		if _, err := os.Stat(imageConfigUrl(imageName)); err != nil {
+			continue
+		}
+		images = append(images, imageName)
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// 镜像 tar 包与配置文件的大小
+func ImageSize(imageName string) int64 {
+	size, _ := DirSize(ImageTarUrl(imageName))
+	configSize, _ := DirSize(imageConfigUrl(imageName))
+	return size + configSize
+}
+
+// 镜像解压后的只读层大小，未解压时返回 0
+func ImageLayerSize(imageName string) int64 {
+	size, _ := DirSize(imageLayerUrl(imageName))
+	return size
+}
+
+// 删除镜像解压后的只读层，下次使用时会重新解压
+func RemoveImageLayer(imageName string) error {
+	return os.RemoveAll(imageLayerUrl(imageName))
+}
+
+// 删除镜像的 tar 包、配置文件与只读层
+func RemoveImage(imageName string) error {
+	if err := RemoveImageLayer(imageName); err != nil {
+		return err
+	}
+	if err := os.Remove(imageConfigUrl(imageName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(ImageTarUrl(imageName))
+}
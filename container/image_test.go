@@ -0,0 +1,138 @@
+package container
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImageKey(t *testing.T) {
+	cases := map[string]string{
+		"busybox":           "busybox",
+		"busybox:latest":    "busybox",
+		"busybox:1.32":      "busybox_1.32",
+		"localhost:5000/bb": "localhost:5000/bb",
+	}
+	for name, expect := range cases {
+		if key := ImageKey(name); key != expect {
+			t.Fatalf("ImageKey %s got %s, expect %s", name, key, expect)
+		}
+	}
+}
+
+func TestImageConfigApplyChange(t *testing.T) {
+	config := &ImageConfig{}
+	changes := []string{
+		`CMD ["top", "-b"]`,
+		`ENTRYPOINT /entry.sh`,
+		`ENV A=1 B=2`,
+		`ENV GREETING hello world`,
+		`STOPSIGNAL SIGQUIT`,
+		`HEALTHCHECK --interval=5s CMD cat /tmp/ok`,
+	}
+	for _, change := range changes {
+		if err := config.ApplyChange(change); err != nil {
+			t.Fatalf("apply %s error %v", change, err)
+		}
+	}
+	if !reflect.DeepEqual(config.Cmd, []string{"top", "-b"}) {
+		t.Fatalf("cmd %v", config.Cmd)
+	}
+	if !reflect.DeepEqual(config.Env, []string{"A=1", "B=2", "GREETING=hello world"}) {
+		t.Fatalf("env %v", config.Env)
+	}
+	// shell 形式的 ENTRYPOINT 忽略 CMD 与 run 时指定的命令
+	if cmd := config.Command(nil); !reflect.DeepEqual(cmd, []string{"/bin/sh", "-c", "/entry.sh"}) {
+		t.Fatalf("command %v", cmd)
+	}
+	if cmd := config.Command([]string{"echo", "hi"}); !reflect.DeepEqual(cmd, []string{"/bin/sh", "-c", "/entry.sh"}) {
+		t.Fatalf("command with args %v", cmd)
+	}
+	if err := config.ApplyChange(`ENTRYPOINT ["/entry.sh"]`); err != nil {
+		t.Fatal(err)
+	}
+	if cmd := config.Command(nil); !reflect.DeepEqual(cmd, []string{"/entry.sh", "top", "-b"}) {
+		t.Fatalf("exec form command %v", cmd)
+	}
+	if cmd := config.Command([]string{"echo"}); !reflect.DeepEqual(cmd, []string{"/entry.sh", "echo"}) {
+		t.Fatalf("exec form command with args %v", cmd)
+	}
+	if config.StopSignal != "SIGQUIT" {
+		t.Fatalf("stop signal %s", config.StopSignal)
+	}
+	if h := config.Healthcheck; h == nil || !reflect.DeepEqual(h.Test, []string{"CMD-SHELL", "cat /tmp/ok"}) || h.Interval != 5*time.Second {
+		t.Fatalf("healthcheck %+v", h)
+	}
+	// 引号中的空白属于值，同名变量被替换
+	for _, change := range []string{`ENV MSG="hello world" PATH='/a b' ESC=x\ y`, `ENV A=override`, `ENV GREETING="hi \"there\""`} {
+		if err := config.ApplyChange(change); err != nil {
+			t.Fatalf("apply %s error %v", change, err)
+		}
+	}
+	expectEnv := []string{"A=override", "B=2", `GREETING=hi "there"`, "MSG=hello world", "PATH=/a b", "ESC=x y"}
+	if !reflect.DeepEqual(config.Env, expectEnv) {
+		t.Fatalf("env %q, expect %q", config.Env, expectEnv)
+	}
+	for _, change := range []string{`ENV A="unterminated`, `ENV A=1 =2`, `ENV A=1 B`} {
+		if err := config.ApplyChange(change); err == nil {
+			t.Fatalf("apply %s should fail", change)
+		}
+	}
+	if err := config.ApplyChange("VOLUME /data"); err == nil {
+		t.Fatal("unsupported instruction should fail")
+	}
+	if err := config.ApplyChange("STOPSIGNAL SIGFOO"); err == nil {
+		t.Fatal("invalid stop signal should fail")
+	}
+}
+
+func TestListImages(t *testing.T) {
+	defer SetRootUrl(RootUrl)
+	SetRootUrl(t.TempDir())
+	for _, name := range []string{"busybox.tar", "busybox.json", "web.tar", "web.json", "backup.tar"} {
+		if err := ioutil.WriteFile(filepath.Join(RootUrl, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 没有镜像配置的 tar 包不是 ydocker 管理的镜像
+	images, err := ListImages()
+	if err != nil || !reflect.DeepEqual(images, []string{"busybox", "web"}) {
+		t.Fatalf("ListImages got %v error %v", images, err)
+	}
+}
+
+// 读取部分内容后返回错误的 Reader
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestImportImageKeepsOldImageOnFailure(t *testing.T) {
+	defer SetRootUrl(RootUrl)
+	SetRootUrl(t.TempDir())
+	if err := ImportImage(strings.NewReader("old image"), "busybox", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ImportImage(&failingReader{data: []byte("new")}, "busybox", nil); err == nil {
+		t.Fatal("import from failing reader should fail")
+	}
+	if content, err := ioutil.ReadFile(ImageTarUrl("busybox")); err != nil || string(content) != "old image" {
+		t.Fatalf("old image got %q error %v", content, err)
+	}
+	if _, err := os.Stat(ImageTarUrl("busybox") + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temp file of failed import should be removed, stat error %v", err)
+	}
+}
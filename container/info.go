@@ -1,12 +1,21 @@
 package container
 
+import "github.com/yourtion/ydocker/cgroups/subsystems"
+
 var (
+	CREATED             = "created"
 	RUNNING             = "running"
-	STOP                = "stopped"
+	PAUSED              = "paused"
+	RESTARTING          = "restarting"
 	Exit                = "exited"
+	DEAD                = "dead"
+	StateDir            = "/var/run/ydocker"
 	DefaultInfoLocation = "/var/run/ydocker/%s/"
 	ConfigName          = "config.json"
 	LogFile             = "container.log"
+	ShimLogFile         = "shim.log"
+	ExecFifo            = "exec.fifo" // create 创建的容器通过该 FIFO 等待 start 命令
+	TimeFormat          = "2006-01-02 15:04:05"
 	RootUrl             = "/root"
 	MntUrl              = "/root/mnt/%s"
 	WriteLayerUrl       = "/root/writeLayer/%s"
@@ -14,12 +23,49 @@ var (
 )
 
 type Info struct {
-	Pid         string   `json:"pid"`         // 容器的init进程在宿主机上的 PID
-	Id          string   `json:"id"`          // 容器Id
-	Name        string   `json:"name"`        // 容器名
-	Command     string   `json:"command"`     // 容器内init运行命令
-	CreatedTime string   `json:"createTime"`  // 创建时间
-	Status      string   `json:"status"`      // 容器的状态
-	Volume      string   `json:"volume"`      // 容器的数据卷
-	PortMapping []string `json:"portMapping"` // 端口映射
+	Pid           string                     `json:"pid"`                   // 容器的init进程在宿主机上的 PID
+	Id            string                     `json:"id"`                    // 容器Id
+	Name          string                     `json:"name"`                  // 容器名
+	Command       string                     `json:"command"`               // 容器内init运行命令
+	CreatedTime   string                     `json:"createTime"`            // 创建时间
+	Status        string                     `json:"status"`                // 容器的状态
+	Volume        string                     `json:"volume"`                // 容器的数据卷
+	PortMapping   []string                   `json:"portMapping"`           // 端口映射
+	Image         string                     `json:"image"`                 // 容器使用的镜像
+	Network       string                     `json:"network"`               // 容器连接的网络
+	Args          []string                   `json:"args"`                  // 容器内init运行命令的参数列表
+	Env           []string                   `json:"env"`                   // 用户指定的环境变量
+	Resource      *subsystems.ResourceConfig `json:"resource"`              // 资源限制
+	CgroupPath    string                     `json:"cgroupPath"`            // 容器的 cgroup 路径
+	IPAddress     string                     `json:"ip"`                    // 容器在网络中分配的 IP
+	ExitCode      int                        `json:"exitCode"`              // 容器进程的退出码，-1 表示未知
+	OOMKilled     bool                       `json:"oomKilled"`             // 是否因为内存超过限制被杀死
+	StartedTime   string                     `json:"startedTime"`           // 最近一次启动时间
+	FinishedTime  string                     `json:"finishedTime"`          // 最近一次退出时间
+	MonitorPid    int                        `json:"monitorPid"`            // 等待容器退出的父进程（shim 或前台的 run）的 PID
+	RestartPolicy RestartPolicy              `json:"restartPolicy"`         // 容器退出后的重启策略
+	RestartCount  int                        `json:"restartCount"`          // 按照重启策略重启的次数
+	ManualStop    bool                       `json:"manualStop"`            // 是否被 stop 手动停止，手动停止的容器不会被重启
+	StopSignal    string                     `json:"stopSignal"`            // 停止容器时发送的信号，为空时使用 SIGTERM
+	AutoRemove    bool                       `json:"autoRemove"`            // 容器退出后是否自动删除
+	Labels        map[string]string          `json:"labels,omitempty"`      // 用户指定的标签
+	Healthcheck   *HealthConfig              `json:"healthcheck,omitempty"` // 健康检查配置
+	Health        *Health                    `json:"health,omitempty"`      // 最近的健康检查状态
+	Ulimits       []*Ulimit                  `json:"ulimits,omitempty"`     // init 进程的资源限制
+	Sysctls       map[string]string          `json:"sysctls,omitempty"`     // 容器 Namespace 中的内核参数
+	Hostname      string                     `json:"hostname"`              // 容器的主机名，默认为短 ID
+	Domainname    string                     `json:"domainname,omitempty"`  // 容器的域名
+}
+
+// 设置镜像与容器文件系统的存放目录
+func SetRootUrl(root string) {
+	RootUrl = root
+	MntUrl = root + "/mnt/%s"
+	WriteLayerUrl = root + "/writeLayer/%s"
+}
+
+// 设置容器信息的存放目录
+func SetStateDir(dir string) {
+	StateDir = dir
+	DefaultInfoLocation = dir + "/%s/"
 }
@@ -0,0 +1,72 @@
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 解析 --label 与 --label-file 指定的标签，--label 的优先级更高
+func ParseLabels(labels, labelFiles []string) (map[string]string, error) {
+	var lines []string
+	for _, labelFile := range labelFiles {
+		fileLines, err := readLabelFile(labelFile)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, fileLines...)
+	}
+	lines = append(lines, labels...)
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]string, len(lines))
+	for _, line := range lines {
+		// 与 docker 一样，只有 key 没有 value 的标签值为空字符串
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if key == "" {
+			return nil, fmt.Errorf("invalid label '%s': empty name", line)
+		}
+		value := ""
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// 读取标签文件，每行一个 key=value，忽略空行与 # 开头的注释
+func readLabelFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open label file %s error: %v", path, err)
+	}
+	defer file.Close()
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read label file %s error: %v", path, err)
+	}
+	return lines, nil
+}
+
+// 按照 key 排序后以 k=v,k=v 的形式展示标签
+func FormatLabels(labels map[string]string) string {
+	items := make([]string, 0, len(labels))
+	for key, value := range labels {
+		items = append(items, key+"="+value)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
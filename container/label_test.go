@@ -0,0 +1,33 @@
+package container
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	labelFile := filepath.Join(t.TempDir(), "labels")
+	content := "# team labels\nteam=infra\n\njob=build\n"
+	if err := ioutil.WriteFile(labelFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	labels, err := ParseLabels([]string{"job=deploy", "canary", "expr=a=b"}, []string{labelFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{"team": "infra", "job": "deploy", "canary": "", "expr": "a=b"}
+	if !reflect.DeepEqual(labels, expect) {
+		t.Fatalf("ParseLabels got %v, expect %v", labels, expect)
+	}
+	if FormatLabels(labels) != "canary=,expr=a=b,job=deploy,team=infra" {
+		t.Fatalf("FormatLabels got %s", FormatLabels(labels))
+	}
+	if _, err := ParseLabels([]string{"=value"}, nil); err == nil {
+		t.Fatal("label without name should fail")
+	}
+	if _, err := ParseLabels(nil, []string{filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Fatal("missing label file should fail")
+	}
+}
@@ -0,0 +1,134 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// /proc/<pid>/stat 中时间的单位，Linux 上 USER_HZ 固定为 100
+const clockTicks = 100
+
+// top 展示的进程信息
+type ProcessInfo struct {
+	Pid        int           // 宿主机上的 PID
+	NsPid      int           // 容器 PID Namespace 中的 PID
+	PPid       int           // 宿主机上的父进程 PID
+	User       string        // 进程的真实用户，找不到用户名时为 UID
+	CPUTime    time.Duration // 累计使用的 CPU 时间
+	CPUPercent float64       // 进程启动以来的平均 CPU 使用率
+	Command    string        // 完整的命令行，内核线程使用 [comm]
+}
+
+// 从 /proc 读取进程信息
+func ReadProcess(pid int) (*ProcessInfo, error) {
+	procDir := fmt.Sprintf("/proc/%d/", pid)
+	status, err := ioutil.ReadFile(procDir + "status")
+	if err != nil {
+		return nil, err
+	}
+	stat, err := ioutil.ReadFile(procDir + "stat")
+	if err != nil {
+		return nil, err
+	}
+	uptime, err := ioutil.ReadFile("/proc/uptime")
+	if err != nil {
+		return nil, err
+	}
+	cmdline, err := ioutil.ReadFile(procDir + "cmdline")
+	if err != nil {
+		return nil, err
+	}
+	info := &ProcessInfo{Pid: pid}
+	uid, err := parseProcStatus(string(status), info)
+	if err != nil {
+		return nil, err
+	}
+	info.User = uid
+	if u, err := user.LookupId(uid); err == nil {
+		info.User = u.Username
+	}
+	comm, err := parseProcStat(string(stat), string(uptime), info)
+	if err != nil {
+		return nil, err
+	}
+	info.Command = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	if info.Command == "" {
+		info.Command = "[" + comm + "]"
+	}
+	return info, nil
+}
+
+// 解析 /proc/<pid>/status 中的 PPid 与 NSpid，返回真实 UID
+func parseProcStatus(content string, info *ProcessInfo) (string, error) {
+	var uid string
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) == 0 {
+			continue
+		}
+		switch parts[0] {
+		case "Uid":
+			uid = fields[0]
+		case "PPid":
+			info.PPid, _ = strconv.Atoi(fields[0])
+		case "NSpid":
+			// NSpid 从外到内列出进程在各级 PID Namespace 中的 PID，最后一个为容器中的 PID
+			info.NsPid, _ = strconv.Atoi(fields[len(fields)-1])
+		}
+	}
+	if uid == "" {
+		return "", fmt.Errorf("missing Uid in process %d status", info.Pid)
+	}
+	// 内核不支持 NSpid 时无法得到容器中的 PID
+	if info.NsPid == 0 {
+		info.NsPid = info.Pid
+	}
+	return uid, nil
+}
+
+/*
+解析 /proc/<pid>/stat 计算 CPU 时间与使用率，返回进程名
+	1. 进程名在括号中并且可能包含空格，从最后一个 ) 之后按空格切分其他字段
+	2. utime、stime 与 starttime 分别为第 14、15、22 个字段，单位为 clockTicks
+*/
+func parseProcStat(stat, uptime string, info *ProcessInfo) (string, error) {
+	start, end := strings.Index(stat, "("), strings.LastIndex(stat, ")")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("invalid stat of process %d", info.Pid)
+	}
+	comm := stat[start+1 : end]
+	// 第 3 个字段（state）开始
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 {
+		return "", fmt.Errorf("invalid stat of process %d", info.Pid)
+	}
+	var ticks [3]float64
+	for i, index := range []int{11, 12, 19} {
+		value, err := strconv.ParseFloat(fields[index], 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid stat of process %d: %v", info.Pid, err)
+		}
+		ticks[i] = value
+	}
+	info.CPUTime = time.Duration((ticks[0] + ticks[1]) * float64(time.Second) / clockTicks)
+	uptimeFields := strings.Fields(uptime)
+	if len(uptimeFields) == 0 {
+		return "", fmt.Errorf("invalid uptime %q", uptime)
+	}
+	seconds, err := strconv.ParseFloat(uptimeFields[0], 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid uptime %q", uptime)
+	}
+	if elapsed := seconds - ticks[2]/clockTicks; elapsed > 0 {
+		info.CPUPercent = info.CPUTime.Seconds() / elapsed * 100
+	}
+	return comm, nil
+}
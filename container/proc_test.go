@@ -0,0 +1,51 @@
+package container
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseProcStatus(t *testing.T) {
+	content := "Name:\tsleep\nState:\tS (sleeping)\nPid:\t4242\nPPid:\t4200\n" +
+		"Uid:\t1000\t1000\t1000\t1000\nNSpid:\t4242\t7\n"
+	info := &ProcessInfo{Pid: 4242}
+	uid, err := parseProcStatus(content, info)
+	if err != nil || uid != "1000" || info.PPid != 4200 || info.NsPid != 7 {
+		t.Fatalf("parseProcStatus got uid %s %+v error %v", uid, info, err)
+	}
+	info = &ProcessInfo{Pid: 10}
+	if _, err := parseProcStatus("Uid:\t0\t0\t0\t0\n", info); err != nil || info.NsPid != 10 {
+		t.Fatalf("missing NSpid should fall back to pid, got %+v error %v", info, err)
+	}
+	if _, err := parseProcStatus("Name:\tsleep\n", &ProcessInfo{}); err == nil {
+		t.Fatal("missing Uid should fail")
+	}
+}
+
+func TestParseProcStat(t *testing.T) {
+	stat := "4242 (my app) S 4200 4242 4200 0 -1 4194560 100 0 0 0 150 50 0 0 20 0 1 0 1000 1000000 100 " +
+		"18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0"
+	info := &ProcessInfo{Pid: 4242}
+	comm, err := parseProcStat(stat, "30.00 50.00\n", info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// utime + stime = 200 ticks = 2s，进程在 10s 时启动，运行了 20s
+	if comm != "my app" || info.CPUTime != 2*time.Second || info.CPUPercent != 10 {
+		t.Fatalf("parseProcStat got %s %+v", comm, info)
+	}
+	if _, err := parseProcStat("4242 (app) S 1 2", "30.00 50.00", info); err == nil {
+		t.Fatal("short stat should fail")
+	}
+}
+
+func TestReadProcess(t *testing.T) {
+	info, err := ReadProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Pid != os.Getpid() || info.PPid != os.Getppid() || info.User == "" || info.Command == "" {
+		t.Fatalf("unexpected process %+v", info)
+	}
+}
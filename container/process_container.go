@@ -16,7 +16,7 @@ import (
 	3. 下面的 clone 参数就是去 fork 出来一个新进程，并且使用了 namespace 隔离新创建的进程和外部环境
 	4. 如果用户指定了 -ti 参数，就需要把当前进程的输入输出导入到标准输入输出上
 */
-func NewParentProcess(tty bool, containerName, volume, imageName string, envSlice []string) (*exec.Cmd, *os.File) {
+func NewParentProcess(tty bool, containerId, volume, imageName string, envSlice []string) (*exec.Cmd, *os.File) {
 	readPipe, writePipe, err := newPipe()
 	if err != nil {
 		log.Errorf("New pipe error %v", err)
@@ -32,13 +32,14 @@ func NewParentProcess(tty bool, containerName, volume, imageName string, envSlic
 		cmd.Stderr = os.Stderr
 	} else {
 		// 生成容器对应目录的 container.log 文件
-		dirURL := fmt.Sprintf(DefaultInfoLocation, containerName)
+		dirURL := fmt.Sprintf(DefaultInfoLocation, containerId)
 		if err := os.MkdirAll(dirURL, 0622); err != nil {
 			log.Errorf("NewParentProcess mkdir %s error %v", dirURL, err)
 			return nil, nil
 		}
 		stdLogFilePath := dirURL + LogFile
-		stdLogFile, err := os.Create(stdLogFilePath)
+		// 容器重新启动时保留之前的日志
+		stdLogFile, err := os.OpenFile(stdLogFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err != nil {
 			log.Errorf("NewParentProcess create file %s error %v", stdLogFilePath, err)
 			return nil, nil
@@ -50,11 +51,21 @@ func NewParentProcess(tty bool, containerName, volume, imageName string, envSlic
 	cmd.ExtraFiles = []*os.File{readPipe}
 	// 设置环境变量
 	cmd.Env = append(os.Environ(), envSlice...)
-	newWorkSpace(volume, imageName, containerName)
-	cmd.Dir = fmt.Sprintf(MntUrl, containerName)
+	newWorkSpace(volume, imageName, containerId)
+	cmd.Dir = fmt.Sprintf(MntUrl, containerId)
 	return cmd, writePipe
 }
 
+// 容器进程启动后关闭父进程中传给容器的日志文件与管道读端，子进程已经继承了这些文件，重启时不会泄漏
+func CloseChildFiles(cmd *exec.Cmd) {
+	if file, ok := cmd.Stdout.(*os.File); ok && file != os.Stdout {
+		_ = file.Close()
+	}
+	for _, file := range cmd.ExtraFiles {
+		_ = file.Close()
+	}
+}
+
 // 使用 Go 提供的 pipe 方法生成一个匿名管道
 func newPipe() (*os.File, *os.File, error) {
 	// 返回两个变量，一个是读一个是写，其类型都是文件类型
@@ -1,28 +1,55 @@
 package container
 
 import (
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 	"syscall"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 )
 
+// 父进程通过管道以 JSON 格式发送给容器 init 进程的配置
+type InitConfig struct {
+	Args    []string          `json:"args"`              // 用户命令及参数
+	Ulimits []*Ulimit         `json:"ulimits,omitempty"` // 执行用户命令前设置的资源限制
+	Sysctls map[string]string `json:"sysctls,omitempty"` // 执行用户命令前设置的内核参数
+	// 容器 UTS Namespace 中的主机名与域名
+	Hostname   string `json:"hostname,omitempty"`
+	Domainname string `json:"domainname,omitempty"`
+	// 绑定挂载到容器中的文件，key 为容器中的路径，value 为宿主机上的路径
+	Files map[string]string `json:"files,omitempty"`
+}
+
 /*
 这里的 init 函数是在容器内部执行的，也就是说，代码执行到这里后，容器所在的进程其实就已经创建出来了，这是本容器执行的第一个进程。
 使用 mount 先去挂载 proc 文件系统，以便后面通过 ps 等系统命令去查看当前进程资源的情况。
 */
 func RunContainerInitProcess() error {
-	cmdArray := readUserCommand()
-	if cmdArray == nil || len(cmdArray) == 0 {
+	initConfig := readInitConfig()
+	if initConfig == nil || len(initConfig.Args) == 0 {
 		return fmt.Errorf("run container get user commands error, cmdArray is nil")
 	}
+	cmdArray := initConfig.Args
 
-	setUpMount()
+	setUpMount(initConfig.Files)
+
+	if err := setHostname(initConfig.Hostname, initConfig.Domainname); err != nil {
+		logrus.Errorf("Set hostname error %v", err)
+		return err
+	}
+	if err := setSysctls(initConfig.Sysctls); err != nil {
+		logrus.Errorf("Set sysctls error %v", err)
+		return err
+	}
+	// 资源限制会被 exec 之后的用户进程继承
+	if err := setUlimits(initConfig.Ulimits); err != nil {
+		logrus.Errorf("Set ulimits error %v", err)
+		return err
+	}
 
 	// 调用 exec.LookPath，可以在系统的 PATH 里面寻找命令的绝对路径
 	path, err := exec.LookPath(cmdArray[0])
@@ -37,20 +64,33 @@ func RunContainerInitProcess() error {
 	return nil
 }
 
-func readUserCommand() []string {
+func readInitConfig() *InitConfig {
 	// uintptr(3) 就是指 index 为 3 的文件描述符，也就是传递进来的管道的一端
 	pipe := os.NewFile(uintptr(3), "pipe")
-	msg, err := ioutil.ReadAll(pipe)
-	if err != nil {
+	initConfig := &InitConfig{}
+	if err := json.NewDecoder(pipe).Decode(initConfig); err != nil {
 		logrus.Errorf("init read pipe error %v", err)
 		return nil
 	}
-	msgStr := string(msg)
-	return strings.Split(msgStr, " ")
+	return initConfig
+}
+
+// 通过 setrlimit 设置当前进程的资源限制
+func setUlimits(ulimits []*Ulimit) error {
+	for _, ulimit := range ulimits {
+		resource, rlimit, err := ulimit.Rlimit()
+		if err != nil {
+			return err
+		}
+		if err := unix.Setrlimit(resource, rlimit); err != nil {
+			return fmt.Errorf("setrlimit %s error: %v", ulimit, err)
+		}
+	}
+	return nil
 }
 
-// Init 挂载点
-func setUpMount() {
+// Init 挂载点，files 为需要绑定挂载到容器中的文件
+func setUpMount(files map[string]string) {
 	// 获取当前路径
 	pwd, err := os.Getwd()
 	if err != nil {
@@ -58,6 +98,13 @@ func setUpMount() {
 		return
 	}
 	logrus.Infof("Current location is %s", pwd)
+	// 容器中的挂载不传播到宿主机，容器退出后随 Mount Namespace 一起释放
+	if err := syscall.Mount("", "/", "", syscall.MS_PRIVATE|syscall.MS_REC, ""); err != nil {
+		logrus.Errorf("Make mount namespace private error %v", err)
+	}
+	if err := bindMountFiles(pwd, files); err != nil {
+		logrus.Errorf("Bind mount files error %v", err)
+	}
 	if err := pivotRoot(pwd); err != nil {
 		logrus.Errorf("pivotRoot error %v", err)
 	}
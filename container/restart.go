@@ -0,0 +1,95 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 重启策略
+const (
+	RestartNo            = "no"
+	RestartOnFailure     = "on-failure"
+	RestartAlways        = "always"
+	RestartUnlessStopped = "unless-stopped"
+)
+
+const (
+	// 第一次重启前等待的时间，之后每次重启翻倍
+	restartInitialDelay = 100 * time.Millisecond
+	// 重启等待时间的上限
+	restartMaxDelay = time.Minute
+)
+
+// 容器退出后由父进程执行的重启策略
+type RestartPolicy struct {
+	Name              string `json:"name"`
+	MaximumRetryCount int    `json:"maximumRetryCount"` // on-failure 的最大重启次数，0 表示不限制
+}
+
+// 解析 no、on-failure[:N]、always、unless-stopped 格式的重启策略
+func ParseRestartPolicy(policy string) (RestartPolicy, error) {
+	if policy == "" {
+		return RestartPolicy{Name: RestartNo}, nil
+	}
+	parts := strings.SplitN(policy, ":", 2)
+	p := RestartPolicy{Name: parts[0]}
+	switch p.Name {
+	case RestartNo, RestartAlways, RestartUnlessStopped:
+		if len(parts) == 2 {
+			return p, fmt.Errorf("maximum retry count cannot be used with restart policy '%s'", p.Name)
+		}
+	case RestartOnFailure:
+		if len(parts) == 2 {
+			count, err := strconv.Atoi(parts[1])
+			if err != nil || count < 0 {
+				return p, fmt.Errorf("invalid maximum retry count '%s'", parts[1])
+			}
+			p.MaximumRetryCount = count
+		}
+	default:
+		return p, fmt.Errorf("invalid restart policy '%s'", policy)
+	}
+	return p, nil
+}
+
+func (p RestartPolicy) String() string {
+	if p.Name == "" {
+		return RestartNo
+	}
+	if p.Name == RestartOnFailure && p.MaximumRetryCount > 0 {
+		return fmt.Sprintf("%s:%d", p.Name, p.MaximumRetryCount)
+	}
+	return p.Name
+}
+
+/*
+根据重启策略判断退出的容器是否需要重新启动
+	1. 被 stop 手动停止的容器都不会重启，没有常驻的守护进程时 always 与 unless-stopped 的行为一致
+	2. on-failure 只在退出码不为 0 时重启，并受最大重启次数限制
+*/
+func (p RestartPolicy) ShouldRestart(exitCode, restartCount int, manualStop bool) bool {
+	if manualStop {
+		return false
+	}
+	switch p.Name {
+	case RestartAlways, RestartUnlessStopped:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0 && (p.MaximumRetryCount == 0 || restartCount < p.MaximumRetryCount)
+	}
+	return false
+}
+
+// 第 attempt 次连续重启前等待的时间，从 100ms 开始指数增长，最长 1 分钟
+func RestartDelay(attempt int) time.Duration {
+	delay := restartInitialDelay
+	for i := 0; i < attempt && delay < restartMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > restartMaxDelay {
+		delay = restartMaxDelay
+	}
+	return delay
+}
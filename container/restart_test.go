@@ -0,0 +1,51 @@
+package container
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	valid := map[string]RestartPolicy{
+		"":               {Name: RestartNo},
+		"no":             {Name: RestartNo},
+		"always":         {Name: RestartAlways},
+		"unless-stopped": {Name: RestartUnlessStopped},
+		"on-failure":     {Name: RestartOnFailure},
+		"on-failure:3":   {Name: RestartOnFailure, MaximumRetryCount: 3},
+	}
+	for policy, expect := range valid {
+		p, err := ParseRestartPolicy(policy)
+		if err != nil || p != expect {
+			t.Fatalf("ParseRestartPolicy %s got %v error %v, expect %v", policy, p, err, expect)
+		}
+	}
+	for _, policy := range []string{"yes", "always:3", "on-failure:-1", "on-failure:x"} {
+		if _, err := ParseRestartPolicy(policy); err == nil {
+			t.Fatalf("ParseRestartPolicy %s should fail", policy)
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	onFailure := RestartPolicy{Name: RestartOnFailure, MaximumRetryCount: 2}
+	if !onFailure.ShouldRestart(1, 1, false) || onFailure.ShouldRestart(1, 2, false) || onFailure.ShouldRestart(0, 0, false) {
+		t.Fatal("on-failure:2 restart decision error")
+	}
+	always := RestartPolicy{Name: RestartAlways}
+	if !always.ShouldRestart(0, 100, false) || always.ShouldRestart(1, 0, true) {
+		t.Fatal("always restart decision error")
+	}
+	if (RestartPolicy{}).ShouldRestart(1, 0, false) {
+		t.Fatal("empty policy should not restart")
+	}
+}
+
+func TestRestartDelay(t *testing.T) {
+	if RestartDelay(0) != 100*time.Millisecond || RestartDelay(3) != 800*time.Millisecond {
+		t.Fatalf("RestartDelay got %s %s", RestartDelay(0), RestartDelay(3))
+	}
+	if RestartDelay(100) != time.Minute {
+		t.Fatalf("RestartDelay should be limited to 1m, got %s", RestartDelay(100))
+	}
+}
@@ -0,0 +1,26 @@
+package container
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	valid := map[string]syscall.Signal{
+		"SIGHUP":  syscall.SIGHUP,
+		"hup":     syscall.SIGHUP,
+		"sigusr1": syscall.SIGUSR1,
+		"9":       syscall.SIGKILL,
+		"34":      syscall.Signal(34),
+	}
+	for signal, expect := range valid {
+		if sig, err := ParseSignal(signal); err != nil || sig != expect {
+			t.Fatalf("ParseSignal %s got %d error %v, expect %d", signal, sig, err, expect)
+		}
+	}
+	for _, signal := range []string{"", "0", "65", "SIGFOO"} {
+		if _, err := ParseSignal(signal); err == nil {
+			t.Fatalf("ParseSignal %s should fail", signal)
+		}
+	}
+}
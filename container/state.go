@@ -0,0 +1,50 @@
+package container
+
+import "fmt"
+
+// 旧版本中 stop 命令记录的状态，读取时视为 exited
+const legacyStopped = "stopped"
+
+/*
+容器状态机，key 为当前状态，value 为允许转换到的状态
+
+	created -> running -> exited -> running ...
+	running <-> paused，running -> restarting -> running
+	容器进程消失但没有记录退出状态时进入 dead，dead 的容器只能被删除
+*/
+var stateTransitions = map[string][]string{
+	"":            {CREATED},
+	CREATED:       {RUNNING, Exit, DEAD},
+	RUNNING:       {PAUSED, RESTARTING, Exit, DEAD},
+	PAUSED:        {RUNNING, Exit, DEAD},
+	RESTARTING:    {RUNNING, Exit, DEAD},
+	Exit:          {RUNNING, RESTARTING, DEAD},
+	legacyStopped: {RUNNING, Exit, DEAD},
+	DEAD:          {},
+}
+
+// 按照状态机修改容器状态
+func (info *Info) SetStatus(status string) error {
+	for _, next := range stateTransitions[info.Status] {
+		if next == status {
+			info.Status = status
+			return nil
+		}
+	}
+	return fmt.Errorf("container %s can not change status from '%s' to '%s'", info.Name, info.Status, status)
+}
+
+// 容器是否处于运行中（包括暂停与等待重启）
+func (info *Info) IsActive() bool {
+	return info.Status == RUNNING || info.Status == PAUSED || info.Status == RESTARTING
+}
+
+// 容器是否可以被删除或重新启动
+func (info *Info) IsStopped() bool {
+	return info.Status == CREATED || info.Status == Exit || info.Status == DEAD || info.Status == legacyStopped
+}
+
+// 容器是否由 create 创建，init 进程正在等待 start 命令
+func (info *Info) IsPending() bool {
+	return info.Status == CREATED && info.Pid != ""
+}
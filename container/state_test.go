@@ -0,0 +1,23 @@
+package container
+
+import "testing"
+
+func TestInfoSetStatus(t *testing.T) {
+	info := &Info{Name: "test"}
+	for _, status := range []string{CREATED, RUNNING, PAUSED, RUNNING, RESTARTING, RUNNING, Exit, RUNNING, DEAD} {
+		if err := info.SetStatus(status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := info.SetStatus(RUNNING); err == nil {
+		t.Fatal("dead container should not be started")
+	}
+
+	info = &Info{Name: "test", Status: CREATED}
+	if err := info.SetStatus(PAUSED); err == nil {
+		t.Fatal("created container should not be paused")
+	}
+	if !info.IsStopped() || info.IsActive() {
+		t.Fatal("created container should be stopped")
+	}
+}
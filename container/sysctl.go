@@ -0,0 +1,76 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// 容器独立的 IPC Namespace 中可以设置的 sysctl
+var ipcSysctls = map[string]bool{
+	"kernel.msgmax":          true,
+	"kernel.msgmnb":          true,
+	"kernel.msgmni":          true,
+	"kernel.sem":             true,
+	"kernel.shmall":          true,
+	"kernel.shmmax":          true,
+	"kernel.shmmni":          true,
+	"kernel.shm_rmid_forced": true,
+}
+
+/*
+检查 sysctl 是否只作用于容器自己的 Namespace，否则会修改宿主机的内核参数：
+	1. net.* 属于容器的 Network Namespace
+	2. kernel.shm*、kernel.msg*、kernel.sem 与 fs.mqueue.* 属于容器的 IPC Namespace
+*/
+func ValidateSysctl(key string) error {
+	if strings.HasPrefix(key, "net.") || strings.HasPrefix(key, "fs.mqueue.") || ipcSysctls[key] {
+		// 防止通过 .. 访问 /proc/sys 中的其他文件
+		for _, part := range strings.Split(key, ".") {
+			if part == "" {
+				return fmt.Errorf("invalid sysctl '%s'", key)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("sysctl '%s' is not allowed, only namespaced sysctls (net.*, kernel.shm*, kernel.msg*, kernel.sem, fs.mqueue.*) can be set", key)
+}
+
+// 解析 --sysctl 指定的 key=value
+func ParseSysctls(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	sysctls := make(map[string]string, len(values))
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid sysctl '%s', expect key=value", value)
+		}
+		key := strings.TrimSpace(parts[0])
+		if err := ValidateSysctl(key); err != nil {
+			return nil, err
+		}
+		sysctls[key] = parts[1]
+	}
+	return sysctls, nil
+}
+
+// sysctl 对应的 /proc/sys 下的文件
+func sysctlPath(key string) string {
+	return path.Join("/proc/sys", strings.Replace(key, ".", "/", -1))
+}
+
+// 在容器中写入 /proc/sys 设置 sysctl，需要在挂载容器的 /proc 之后调用
+func setSysctls(sysctls map[string]string) error {
+	for key, value := range sysctls {
+		if err := ValidateSysctl(key); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(sysctlPath(key), []byte(value), 0644); err != nil {
+			return fmt.Errorf("set sysctl %s error: %v", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateSysctl(t *testing.T) {
+	for _, key := range []string{"net.core.somaxconn", "net.ipv4.ip_unprivileged_port_start", "kernel.shmmax",
+		"kernel.msgmnb", "kernel.sem", "fs.mqueue.msg_max"} {
+		if err := ValidateSysctl(key); err != nil {
+			t.Fatalf("ValidateSysctl %s error %v", key, err)
+		}
+	}
+	for _, key := range []string{"kernel.hostname", "vm.swappiness", "fs.file-max", "kernel.shm", "net..core", "net.", "net"} {
+		if err := ValidateSysctl(key); err == nil {
+			t.Fatalf("ValidateSysctl %s should fail", key)
+		}
+	}
+}
+
+func TestParseSysctls(t *testing.T) {
+	sysctls, err := ParseSysctls([]string{"net.core.somaxconn=1024", "kernel.sem=250 32000 32 128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{"net.core.somaxconn": "1024", "kernel.sem": "250 32000 32 128"}
+	if !reflect.DeepEqual(sysctls, expect) {
+		t.Fatalf("ParseSysctls got %v, expect %v", sysctls, expect)
+	}
+	if sysctls, err := ParseSysctls(nil); err != nil || sysctls != nil {
+		t.Fatalf("ParseSysctls without sysctls got %v error %v", sysctls, err)
+	}
+	for _, value := range []string{"net.core.somaxconn", "vm.swappiness=10"} {
+		if _, err := ParseSysctls([]string{value}); err == nil {
+			t.Fatalf("ParseSysctls %s should fail", value)
+		}
+	}
+	if sysctlPath("net.ipv4.ip_forward") != "/proc/sys/net/ipv4/ip_forward" {
+		t.Fatalf("sysctlPath got %s", sysctlPath("net.ipv4.ip_forward"))
+	}
+}
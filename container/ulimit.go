@@ -0,0 +1,125 @@
+package container
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// 支持设置的资源限制，与 ulimit 命令中的名称一致
+var ulimitResources = map[string]int{
+	"as":         unix.RLIMIT_AS,
+	"core":       unix.RLIMIT_CORE,
+	"cpu":        unix.RLIMIT_CPU,
+	"data":       unix.RLIMIT_DATA,
+	"fsize":      unix.RLIMIT_FSIZE,
+	"locks":      unix.RLIMIT_LOCKS,
+	"memlock":    unix.RLIMIT_MEMLOCK,
+	"msgqueue":   unix.RLIMIT_MSGQUEUE,
+	"nice":       unix.RLIMIT_NICE,
+	"nofile":     unix.RLIMIT_NOFILE,
+	"nproc":      unix.RLIMIT_NPROC,
+	"rss":        unix.RLIMIT_RSS,
+	"rtprio":     unix.RLIMIT_RTPRIO,
+	"rttime":     unix.RLIMIT_RTTIME,
+	"sigpending": unix.RLIMIT_SIGPENDING,
+	"stack":      unix.RLIMIT_STACK,
+}
+
+// 容器 init 进程的资源限制，-1 表示不限制
+type Ulimit struct {
+	Name string `json:"name"`
+	Soft int64  `json:"soft"`
+	Hard int64  `json:"hard"`
+}
+
+// 解析 name=soft[:hard] 格式的资源限制，没有指定 hard 时与 soft 相同
+func ParseUlimit(value string) (*Ulimit, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid ulimit '%s', expect name=soft[:hard]", value)
+	}
+	if _, ok := ulimitResources[parts[0]]; !ok {
+		return nil, fmt.Errorf("invalid ulimit '%s': unknown type %s", value, parts[0])
+	}
+	limits := strings.SplitN(parts[1], ":", 2)
+	soft, err := parseUlimitValue(limits[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ulimit '%s': %v", value, err)
+	}
+	hard := soft
+	if len(limits) == 2 {
+		if hard, err = parseUlimitValue(limits[1]); err != nil {
+			return nil, fmt.Errorf("invalid ulimit '%s': %v", value, err)
+		}
+	}
+	// -1 表示不限制，比任何值都大
+	if hard != -1 && (soft == -1 || soft > hard) {
+		return nil, fmt.Errorf("invalid ulimit '%s': soft limit is greater than hard limit", value)
+	}
+	return &Ulimit{Name: parts[0], Soft: soft, Hard: hard}, nil
+}
+
+func parseUlimitValue(value string) (int64, error) {
+	if value == "unlimited" {
+		return -1, nil
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < -1 {
+		return 0, fmt.Errorf("invalid limit value %s", value)
+	}
+	return limit, nil
+}
+
+// 解析配置文件中的默认资源限制与 --ulimit 指定的资源限制，同名时 --ulimit 的优先级更高
+func ParseUlimits(defaults, ulimits []string) ([]*Ulimit, error) {
+	byName := map[string]*Ulimit{}
+	// 复制默认值，避免 append 写入全局配置中切片的底层数组
+	values := append(append([]string{}, defaults...), ulimits...)
+	for _, value := range values {
+		ulimit, err := ParseUlimit(value)
+		if err != nil {
+			return nil, err
+		}
+		byName[ulimit.Name] = ulimit
+	}
+	if len(byName) == 0 {
+		return nil, nil
+	}
+	result := make([]*Ulimit, 0, len(byName))
+	for _, ulimit := range byName {
+		result = append(result, ulimit)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// 转换为 setrlimit 使用的资源与限制
+func (u *Ulimit) Rlimit() (int, *unix.Rlimit, error) {
+	resource, ok := ulimitResources[u.Name]
+	if !ok {
+		return 0, nil, fmt.Errorf("unknown ulimit type %s", u.Name)
+	}
+	return resource, &unix.Rlimit{Cur: rlimitValue(u.Soft), Max: rlimitValue(u.Hard)}, nil
+}
+
+func rlimitValue(limit int64) uint64 {
+	if limit < 0 {
+		return unix.RLIM_INFINITY
+	}
+	return uint64(limit)
+}
+
+func (u *Ulimit) String() string {
+	return fmt.Sprintf("%s=%s:%s", u.Name, formatUlimitValue(u.Soft), formatUlimitValue(u.Hard))
+}
+
+func formatUlimitValue(limit int64) string {
+	if limit < 0 {
+		return "unlimited"
+	}
+	return strconv.FormatInt(limit, 10)
+}
@@ -0,0 +1,63 @@
+package container
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseUlimit(t *testing.T) {
+	valid := map[string]Ulimit{
+		"nofile=1024:65536":   {Name: "nofile", Soft: 1024, Hard: 65536},
+		"nproc=512":           {Name: "nproc", Soft: 512, Hard: 512},
+		"core=0:unlimited":    {Name: "core", Soft: 0, Hard: -1},
+		"memlock=-1":          {Name: "memlock", Soft: -1, Hard: -1},
+		"stack=8192:-1":       {Name: "stack", Soft: 8192, Hard: -1},
+		"rttime=unlimited:-1": {Name: "rttime", Soft: -1, Hard: -1},
+	}
+	for value, expect := range valid {
+		ulimit, err := ParseUlimit(value)
+		if err != nil || *ulimit != expect {
+			t.Fatalf("ParseUlimit %s got %v error %v, expect %v", value, ulimit, err, expect)
+		}
+	}
+	for _, value := range []string{"nofile", "files=10", "nofile=x", "nofile=-2", "nofile=10:5", "nofile=unlimited:10"} {
+		if _, err := ParseUlimit(value); err == nil {
+			t.Fatalf("ParseUlimit %s should fail", value)
+		}
+	}
+}
+
+func TestParseUlimits(t *testing.T) {
+	ulimits, err := ParseUlimits([]string{"nofile=1024:4096", "core=0"}, []string{"nofile=65536:65536"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ulimits) != 2 || ulimits[0].String() != "core=0:0" || ulimits[1].String() != "nofile=65536:65536" {
+		t.Fatalf("ParseUlimits got %v", ulimits)
+	}
+	if ulimits, err := ParseUlimits(nil, nil); err != nil || ulimits != nil {
+		t.Fatalf("ParseUlimits without ulimits got %v error %v", ulimits, err)
+	}
+	if _, err := ParseUlimits([]string{"bad"}, nil); err == nil {
+		t.Fatal("invalid default ulimit should fail")
+	}
+
+	// 默认值切片有剩余容量时不能被 --ulimit 覆盖
+	defaults := make([]string, 1, 2)
+	defaults[0] = "core=0"
+	buf := defaults[:2]
+	if _, err := ParseUlimits(defaults, []string{"nofile=1024"}); err != nil || buf[1] != "" {
+		t.Fatalf("ParseUlimits modified defaults %v error %v", buf, err)
+	}
+}
+
+func TestUlimitRlimit(t *testing.T) {
+	resource, rlimit, err := (&Ulimit{Name: "nofile", Soft: 1024, Hard: -1}).Rlimit()
+	if err != nil || resource != unix.RLIMIT_NOFILE || rlimit.Cur != 1024 || rlimit.Max != unix.RLIM_INFINITY {
+		t.Fatalf("Rlimit got %d %+v error %v", resource, rlimit, err)
+	}
+	if _, _, err := (&Ulimit{Name: "files"}).Rlimit(); err == nil {
+		t.Fatal("unknown ulimit should fail")
+	}
+}
@@ -1,7 +1,9 @@
 package container
 
 import (
+	"bufio"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -23,3 +25,40 @@ func volumeUrlExtract(volume string) []string {
 	volumeURLs = strings.Split(volume, ":")
 	return volumeURLs
 }
+
+// 通过 /proc/self/mountinfo 判断路径是否为挂载点
+func isMountPoint(path string) bool {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	path = filepath.Clean(path)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		fields := strings.Split(scanner.Text(), " ")
+		if len(fields) > 4 && fields[4] == path {
+			return true
+		}
+	}
+	return false
+}
+
+// 计算文件或目录占用的大小，不跟随符号链接
+func DirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.Mode().IsRegular() {
+			size += f.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return size, err
+}
@@ -4,22 +4,23 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"strings"
+	"path/filepath"
+	"syscall"
 
 	log "github.com/sirupsen/logrus"
 )
 
 // 创建容器文件系统
-func newWorkSpace(volume, imageName, containerName string) {
+func newWorkSpace(volume, imageName, containerId string) {
 	_ = createReadOnlyLayer(imageName)
-	_ = createWriteLayer(containerName)
-	_ = createMountPoint(containerName, imageName)
+	_ = createWriteLayer(containerId)
+	_ = createMountPoint(containerId, imageName)
 	// 根据 volume 判断是否执行挂载数据卷操作
 	if volume != "" {
 		volumeURLs := volumeUrlExtract(volume)
 		length := len(volumeURLs)
 		if length == 2 && volumeURLs[0] != "" && volumeURLs[1] != "" {
-			_ = mountVolume(volumeURLs, containerName)
+			_ = mountVolume(volumeURLs, containerId)
 			log.Infof("mount volume: %q", volumeURLs)
 		} else {
 			log.Infof("Volume parameter input is not correct.")
@@ -29,8 +30,8 @@ func newWorkSpace(volume, imageName, containerName string) {
 
 // 解压 tar 格式的锐像文件作为只读层
 func createReadOnlyLayer(imageName string) error {
-	unTarFolderUrl := RootUrl + "/" + imageName + "/"
-	imageUrl := RootUrl + "/" + imageName + ".tar"
+	unTarFolderUrl := imageLayerUrl(imageName) + "/"
+	imageUrl := ImageTarUrl(imageName)
 	exist, err := pathExists(unTarFolderUrl)
 	if err != nil {
 		log.Infof("Fail to judge whether dir %s exists. %v", unTarFolderUrl, err)
@@ -51,8 +52,8 @@ func createReadOnlyLayer(imageName string) error {
 }
 
 // 创建了一个名为 writeLayer 的文件夹作为容器唯一的可写层
-func createWriteLayer(containerName string) error {
-	writeURL := fmt.Sprintf(WriteLayerUrl, containerName)
+func createWriteLayer(containerId string) error {
+	writeURL := fmt.Sprintf(WriteLayerUrl, containerId)
 	if err := os.MkdirAll(writeURL, 0777); err != nil {
 		log.Infof("Mkdir write layer dir %s error. %v", writeURL, err)
 		return err
@@ -61,17 +62,17 @@ func createWriteLayer(containerName string) error {
 }
 
 // 创建容器的根目录，然后把镜像只读层和容器读写层挂载到容器根目录，成为容器的文件系统
-func createMountPoint(containerName, imageName string) error {
-	mntUrl := fmt.Sprintf(MntUrl, containerName)
+func createMountPoint(containerId, imageName string) error {
+	mntUrl := fmt.Sprintf(MntUrl, containerId)
 	// 创建 mnt 文件夹作为挂载点
 	if err := os.MkdirAll(mntUrl, 0777); err != nil {
 		log.Errorf("Mkdir dir %s error. %v", mntUrl, err)
 		return err
 	}
 	// 把 writeLayer 目录和 busybox 目录 mount 到 mnt 目录下
-	tmpWriteLayer := fmt.Sprintf(WriteLayerUrl, containerName)
-	tmpImageLocation := RootUrl + "/" + imageName
-	mntURL := fmt.Sprintf(MntUrl, containerName)
+	tmpWriteLayer := fmt.Sprintf(WriteLayerUrl, containerId)
+	tmpImageLocation := imageLayerUrl(imageName)
+	mntURL := fmt.Sprintf(MntUrl, containerId)
 	dirs := "dirs=" + tmpWriteLayer + ":" + tmpImageLocation
 	_, err := exec.Command("mount", "-t", "aufs", "-o", dirs, "none", mntURL).CombinedOutput()
 	if err != nil {
@@ -81,41 +82,60 @@ func createMountPoint(containerName, imageName string) error {
 	return nil
 }
 
-// 当容器退出时，删除容器的相关文件系统
-func DeleteWorkSpace(volume, containerName string) {
-	if volume != "" {
-		volumeURLs := strings.Split(volume, ":")
-		length := len(volumeURLs)
-		if length == 2 && volumeURLs[0] != "" && volumeURLs[1] != "" {
-			_ = deleteMountPointWithVolume(volumeURLs, containerName)
-		} else {
-			_ = deleteMountPoint(containerName)
-		}
-	} else {
-		_ = deleteMountPoint(containerName)
+// 获取容器的根目录，容器未挂载时临时挂载镜像层与可写层，并返回用于卸载的函数
+func MountContainerRoot(containerId, imageName string) (string, func(), error) {
+	mntURL := fmt.Sprintf(MntUrl, containerId)
+	if isMountPoint(mntURL) {
+		return mntURL, func() {}, nil
+	}
+	if exist, err := pathExists(fmt.Sprintf(WriteLayerUrl, containerId)); err != nil || !exist {
+		return "", nil, fmt.Errorf("write layer of container %s not found", containerId)
 	}
-	_ = deleteWriteLayer(containerName)
+	if err := createReadOnlyLayer(imageName); err != nil {
+		return "", nil, err
+	}
+	if err := createMountPoint(containerId, imageName); err != nil {
+		return "", nil, err
+	}
+	return mntURL, func() {
+		UnmountWorkSpace("", containerId)
+	}, nil
 }
 
-// 删除未挂载数据卷的容器文件系统
-func deleteMountPoint(containerName string) error {
-	mntURL := fmt.Sprintf(MntUrl, containerName)
-	// 在 DeleteMountPoint 函数中 umount mnt 目录
-	if _, err := exec.Command("umount", mntURL).CombinedOutput(); err != nil {
-		log.Errorf("%v", err)
-		return err
+// 卸载容器的文件系统，可写层会被保留，容器再次启动时重新挂载
+func UnmountWorkSpace(volume, containerId string) {
+	mntURL := fmt.Sprintf(MntUrl, containerId)
+	// 先卸载容器里 volume 挂载点的文件系统
+	volumeURLs := volumeUrlExtract(volume)
+	if len(volumeURLs) == 2 && volumeURLs[0] != "" && volumeURLs[1] != "" {
+		containerUrl := mntURL + "/" + volumeURLs[1]
+		if isMountPoint(containerUrl) {
+			if _, err := exec.Command("umount", containerUrl).CombinedOutput(); err != nil {
+				log.Errorf("Umount volume %s failed. %v", containerUrl, err)
+			}
+		}
 	}
-	// 删除 mnt 目录
-	if err := os.RemoveAll(mntURL); err != nil {
-		log.Errorf("Remove dir %s error %v", mntURL, err)
-		return err
+	if isMountPoint(mntURL) {
+		if _, err := exec.Command("umount", mntURL).CombinedOutput(); err != nil {
+			log.Errorf("Umount mountpoint %s failed. %v", mntURL, err)
+			return
+		}
 	}
-	return nil
+	// 卸载后挂载点是空目录，使用 os.Remove 避免误删仍然挂载着的内容
+	if err := os.Remove(mntURL); err != nil && !os.IsNotExist(err) {
+		log.Errorf("Remove mountpoint dir %s error %v", mntURL, err)
+	}
+}
+
+// 删除容器时，删除容器的相关文件系统
+func DeleteWorkSpace(volume, containerId string) {
+	UnmountWorkSpace(volume, containerId)
+	_ = deleteWriteLayer(containerId)
 }
 
 // 删除容器的读写层
-func deleteWriteLayer(containerName string) error {
-	writeURL := fmt.Sprintf(WriteLayerUrl, containerName)
+func deleteWriteLayer(containerId string) error {
+	writeURL := fmt.Sprintf(WriteLayerUrl, containerId)
 	if err := os.RemoveAll(writeURL); err != nil {
 		log.Errorf("Remove dir %s error %v", writeURL, err)
 		return err
@@ -123,7 +143,16 @@ func deleteWriteLayer(containerName string) error {
 	return nil
 }
 
-func mountVolume(volumeURLs []string, containerName string) error {
+// 解析 -v 参数，返回宿主机目录与容器内目录
+func ParseVolume(volume string) (string, string, bool) {
+	volumeURLs := volumeUrlExtract(volume)
+	if len(volumeURLs) != 2 || volumeURLs[0] == "" || volumeURLs[1] == "" {
+		return "", "", false
+	}
+	return volumeURLs[0], volumeURLs[1], true
+}
+
+func mountVolume(volumeURLs []string, containerId string) error {
 	// 创建宿主机文件目录
 	parentUrl := volumeURLs[0]
 	if err := os.MkdirAll(parentUrl, 0777); err != nil {
@@ -131,7 +160,7 @@ func mountVolume(volumeURLs []string, containerName string) error {
 	}
 	// 在容器文件系统里创建挂载点
 	containerUrl := volumeURLs[1]
-	mntURL := fmt.Sprintf(MntUrl, containerName)
+	mntURL := fmt.Sprintf(MntUrl, containerId)
 	containerVolumeURL := mntURL + "/" + containerUrl
 	if err := os.MkdirAll(containerVolumeURL, 0777); err != nil {
 		log.Infof("Mkdir container dir %s error. %v", containerVolumeURL, err)
@@ -146,21 +175,80 @@ func mountVolume(volumeURLs []string, containerName string) error {
 	return nil
 }
 
-// 删除挂载数据卷容器的文件系统
-func deleteMountPointWithVolume(volumeURLs []string, containerName string) error {
-	mntURL := fmt.Sprintf(MntUrl, containerName)
-	// 卸载容器里 volume 挂载点的文件系统
-	containerUrl := mntURL + "/" + volumeURLs[1]
-	if _, err := exec.Command("umount", containerUrl).CombinedOutput(); err != nil {
-		log.Errorf("Umount volume %s failed. %v", containerUrl, err)
+// 列出 WriteLayerUrl 与 MntUrl 下存在的容器文件系统，返回容器 ID
+func ListWorkSpaces() ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, pattern := range []string{WriteLayerUrl, MntUrl} {
+		dirs, err := filepath.Glob(fmt.Sprintf(pattern, "*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			if name := filepath.Base(dir); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// 容器可写层的大小
+func WriteLayerSize(containerId string) int64 {
+	size, _ := DirSize(fmt.Sprintf(WriteLayerUrl, containerId))
+	return size
+}
+
+// 清理已经没有容器信息的文件系统，挂载点连同其中的数据卷一起卸载
+func RemoveStaleWorkSpace(containerId string) error {
+	mntURL := fmt.Sprintf(MntUrl, containerId)
+	if isMountPoint(mntURL) {
+		if err := syscall.Unmount(mntURL, syscall.MNT_DETACH); err != nil {
+			return fmt.Errorf("umount %s error: %v", mntURL, err)
+		}
+	}
+	if err := os.Remove(mntURL); err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	if _, err := exec.Command("umount", mntURL).CombinedOutput(); err != nil {
-		log.Errorf("Umount mountpoint %s failed. %v", mntURL, err)
+	return deleteWriteLayer(containerId)
+}
+
+/*
+把旧版本以容器名命名的文件系统迁移到以容器 ID 命名的目录：
+	1. 可写层只是普通目录，直接重命名
+	2. 挂载点正在使用时通过 MS_MOVE 移动挂载，挂载点下的数据卷随之移动
+	3. 挂载无法移动时保留原来的挂载点，容器删除后由 system prune 作为残留文件系统清理
+*/
+func MigrateWorkSpace(oldKey, newKey string) error {
+	if err := moveWorkSpaceDir(WriteLayerUrl, oldKey, newKey); err != nil {
 		return err
 	}
-	if err := os.RemoveAll(mntURL); err != nil {
-		log.Errorf("Remove mountpoint dir %s error %v", mntURL, err)
+	oldMnt, newMnt := fmt.Sprintf(MntUrl, oldKey), fmt.Sprintf(MntUrl, newKey)
+	if !isMountPoint(oldMnt) {
+		return moveWorkSpaceDir(MntUrl, oldKey, newKey)
+	}
+	if err := os.MkdirAll(newMnt, 0777); err != nil {
+		return err
+	}
+	if err := syscall.Mount(oldMnt, newMnt, "", syscall.MS_MOVE, ""); err != nil {
+		_ = os.Remove(newMnt)
+		log.Warnf("Move mount %s to %s error %v, it will be removed by system prune", oldMnt, newMnt, err)
+		return nil
+	}
+	if err := os.Remove(oldMnt); err != nil {
+		log.Warnf("Remove %s error %v", oldMnt, err)
 	}
 	return nil
 }
+
+func moveWorkSpaceDir(pattern, oldKey, newKey string) error {
+	oldURL, newURL := fmt.Sprintf(pattern, oldKey), fmt.Sprintf(pattern, newKey)
+	if exist, err := pathExists(oldURL); err != nil || !exist {
+		return err
+	}
+	if exist, _ := pathExists(newURL); exist {
+		return fmt.Errorf("%s already exists", newURL)
+	}
+	return os.Rename(oldURL, newURL)
+}
@@ -18,12 +18,13 @@ func main() {
 	app.Name = "ydocker"
 	app.Usage = usage
 	app.Commands = commands.GetCommandList()
+	app.Flags = commands.GlobalFlags
 
-	//  app.Before 内初始化一下 logrus 的日志配置
+	//  app.Before 内初始化一下 logrus 的日志配置，并加载全局配置
 	app.Before = func(ctx *cli.Context) error {
 		log.SetFormatter(&log.TextFormatter{})
 		log.SetOutput(os.Stdout)
-		return nil
+		return commands.Setup(ctx)
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -88,8 +88,16 @@ func (d *BridgeNetworkDriver) Connect(network *Network, endpoint *Endpoint) erro
 	return nil
 }
 
+// 删除网络端点在宿主机上的 Veth，容器的 Net Namespace 销毁后 Veth 通常已经被自动删除
 func (d *BridgeNetworkDriver) Disconnect(network Network, endpoint *Endpoint) error {
-	return nil
+	link, err := netlink.LinkByName(endpoint.ID[:5])
+	if err != nil {
+		if _, ok := err.(netlink.LinkNotFoundError); ok {
+			return nil
+		}
+		return err
+	}
+	return netlink.LinkDel(link)
 }
 
 // 初始化 Linux Bridge
@@ -11,7 +11,7 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-const defaultAllocatorPath = "/var/run/ydocker/network/ipam/subnet.json"
+var defaultAllocatorPath = "/var/run/ydocker/network/ipam/subnet.json"
 
 // 地址分配
 type IPAM struct {
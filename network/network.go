@@ -112,6 +112,13 @@ func (nw *Network) load(dumpPath string) error {
 	return nil
 }
 
+// 设置网络配置与 IPAM 分配信息的存放目录
+func SetStateDir(dir string) {
+	defaultNetworkPath = dir + "/network/network/"
+	defaultAllocatorPath = dir + "/network/ipam/subnet.json"
+	ipAllocator.SubnetAllocatorPath = defaultAllocatorPath
+}
+
 func Init() error {
 	// 加载网络驱动
 	var bridgeDriver = BridgeNetworkDriver{}
@@ -145,7 +152,7 @@ func Init() error {
 		networks[nwName] = nw
 		return nil
 	})
-	logrus.Infof("networks: %v", networks)
+	logrus.Debugf("networks: %v", networks)
 	return err
 }
 
@@ -165,7 +172,11 @@ func CreateNetwork(driver, subnet, name string) error {
 		return err
 	}
 	// 保存网络信息，将网络的信息保存在文件系统中，以便查询和在网络上连接网络端点
-	return nw.dump(defaultNetworkPath)
+	if err := nw.dump(defaultNetworkPath); err != nil {
+		return err
+	}
+	container.LogNetworkEvent(name, "create", map[string]string{"type": driver})
+	return nil
 }
 
 // 展示网络列表
@@ -182,6 +193,21 @@ func ListNetwork() {
 	}
 }
 
+// 获取所有网络的名字
+func ListNetworkNames() []string {
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	return names
+}
+
+// 根据网络名获取网络
+func GetNetwork(networkName string) (*Network, bool) {
+	nw, ok := networks[networkName]
+	return nw, ok
+}
+
 // 删除网络
 func DeleteNetwork(networkName string) error {
 	// 查找网络是否存在
@@ -198,7 +224,11 @@ func DeleteNetwork(networkName string) error {
 		return fmt.Errorf("error Remove Network DriverError: %s", err)
 	}
 	// 从网络的配直目录中删除该网络对应的配置文件
-	return nw.remove(defaultNetworkPath)
+	if err := nw.remove(defaultNetworkPath); err != nil {
+		return err
+	}
+	container.LogNetworkEvent(networkName, "destroy", map[string]string{"type": nw.Driver})
+	return nil
 }
 
 // 将容器的网络端点加入到容器的网络空间中
@@ -302,21 +332,30 @@ func configEndpointIpAddressAndRoute(ep *Endpoint, cInfo *container.Info) error
 	return nil
 }
 
+// 生成端口映射的 iptables 参数，action 为 -A 添加或 -D 删除
+func portMappingArgs(action, pm string, ip net.IP) ([]string, error) {
+	// 分割成宿主机的端口和容器的端口
+	portMapping := strings.Split(pm, ":")
+	if len(portMapping) != 2 {
+		return nil, fmt.Errorf("port mapping format error, %v", pm)
+	}
+	// 在 iptables 的 PREROUTING 中添加 DNAT 规则，将宿主机的端口请求转发到容器的地址和端口上
+	iptablesCmd := fmt.Sprintf("-t nat %s PREROUTING -p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
+		action, portMapping[0], ip.String(), portMapping[1])
+	return strings.Split(iptablesCmd, " "), nil
+}
+
 // 配置容器到宿主机的端口映射，
 func configPortMapping(ep *Endpoint, _ *container.Info) error {
 	// 遍历容器端口映射列表
 	for _, pm := range ep.PortMapping {
-		// 分割成宿主机的端口和容器的端口
-		portMapping := strings.Split(pm, ":")
-		if len(portMapping) != 2 {
-			logrus.Errorf("port mapping format error, %v", pm)
+		args, err := portMappingArgs("-A", pm, ep.IPAddress)
+		if err != nil {
+			logrus.Error(err)
 			continue
 		}
 		// 由于 iptables 没有 Go 语言版本的实现，所以采用 exec.Command 的方式直接调用命令配置
-		// 在 iptables 的 PREROUTING 中添加 DNAT 规则，将宿主机的端口请求转发到容器的地址和端口上
-		iptablesCmd := fmt.Sprintf("-t nat -A PREROUTING -p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
-			portMapping[0], ep.IPAddress.String(), portMapping[1])
-		cmd := exec.Command("iptables", strings.Split(iptablesCmd, " ")...)
+		cmd := exec.Command("iptables", args...)
 		// 执行 iptables 命令，添加端口映射转发规则
 		output, err := cmd.Output()
 		if err != nil {
@@ -327,6 +366,19 @@ func configPortMapping(ep *Endpoint, _ *container.Info) error {
 	return nil
 }
 
+// 删除容器到宿主机的端口映射
+func deletePortMapping(ep *Endpoint) {
+	for _, pm := range ep.PortMapping {
+		args, err := portMappingArgs("-D", pm, ep.IPAddress)
+		if err != nil {
+			continue
+		}
+		if output, err := exec.Command("iptables", args...).CombinedOutput(); err != nil {
+			logrus.Errorf("iptables delete port mapping %s error %v: %s", pm, err, output)
+		}
+	}
+}
+
 // 连楼容2带到之前创建的网络 ydocker run net testnet -p 8080:80 xxxx
 func Connect(networkName string, cInfo *container.Info) error {
 	// 从 networks 字典中取到容器连接的网络的信息，如果找不到网络则返回错误
@@ -341,6 +393,8 @@ func Connect(networkName string, cInfo *container.Info) error {
 		return err
 	}
 
+	// 记录分配的 IP，容器退出时释放
+	cInfo.IPAddress = ip.String()
 	// 创建网络端点，设置网络端点的 IP、网络和端口映射信息
 	ep := &Endpoint{
 		ID:          fmt.Sprintf("%s-%s", cInfo.Id, networkName),
@@ -357,9 +411,37 @@ func Connect(networkName string, cInfo *container.Info) error {
 		return err
 	}
 	// 配置容器到宿主机的端口映射
-	return configPortMapping(ep, cInfo)
+	if err = configPortMapping(ep, cInfo); err != nil {
+		return err
+	}
+	container.LogNetworkEvent(networkName, "connect", map[string]string{"container": cInfo.Id})
+	return nil
 }
 
-func Disconnect(networkName string, cinfo *container.Info) error {
+// 将容器从网络中断开，删除端口映射与网络端点并释放容器的 IP
+func Disconnect(networkName string, cInfo *container.Info) error {
+	network, ok := networks[networkName]
+	if !ok {
+		return fmt.Errorf("no Such Network: %s", networkName)
+	}
+	ip := net.ParseIP(cInfo.IPAddress)
+	if ip == nil {
+		return fmt.Errorf("invalid container ip: %s", cInfo.IPAddress)
+	}
+	ep := &Endpoint{
+		ID:          fmt.Sprintf("%s-%s", cInfo.Id, networkName),
+		IPAddress:   ip,
+		Network:     network,
+		PortMapping: cInfo.PortMapping,
+	}
+	deletePortMapping(ep)
+	if err := drivers[network.Driver].Disconnect(*network, ep); err != nil {
+		logrus.Errorf("disconnect endpoint %s error %v", ep.ID, err)
+	}
+	if err := ipAllocator.Release(network.IpRange, &ip); err != nil {
+		return err
+	}
+	cInfo.IPAddress = ""
+	container.LogNetworkEvent(networkName, "disconnect", map[string]string{"container": cInfo.Id})
 	return nil
 }
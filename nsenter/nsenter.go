@@ -8,6 +8,7 @@ package nsenter
 #include <stdlib.h>
 #include <string.h>
 #include <fcntl.h>
+#include <sys/wait.h>
 
 // 这里的 attribute((constructor)) 指的是， 一旦这个包被引用，那么这个函数就会被自动执行
 // 类似于构造函数，会在程序一启动的时候运行
@@ -40,17 +41,24 @@ __attribute__((constructor)) void enter_namespace(void) {
 		sprintf(nspath, "/proc/%s/ns/%s", ydocker_pid, namespaces[i]);
 		int fd = open(nspath, O_RDONLY);
 		// 这里才真正调用 setns 系统调用进入对应的 Namespace
-		if (setns(fd, 0) == -1) {
-			// fprintf(stderr, "setns on %s namespace failed: %s\n", namespaces[i], strerror(errno));
-		} else {
-			// fprintf(stdout, "setns on %s namespace succeeded\n", namespaces[i]);
+		// 容器已经退出时不能进入 Namespace，直接退出，避免命令在宿主机上执行
+		if (fd == -1 || setns(fd, 0) == -1) {
+			fprintf(stderr, "setns on %s namespace failed: %s\n", namespaces[i], strerror(errno));
+			exit(126);
 		}
+		// fprintf(stdout, "setns on %s namespace succeeded\n", namespaces[i]);
 		close(fd);
 	}
 	// 在进入的 Namespace 中执行指定的命令
 	int res = system(ydocker_cmd);
-	// 退出
-	exit(0);
+	// 以命令的退出码退出，健康检查根据退出码判断结果，被信号杀死时与 shell 一样使用 128 + 信号值
+	if (res == -1) {
+		exit(127);
+	}
+	if (WIFSIGNALED(res)) {
+		exit(128 + WTERMSIG(res));
+	}
+	exit(WEXITSTATUS(res));
 	return;
 }
 */